@@ -39,6 +39,65 @@ type Requester interface {
 	Teardown() error
 }
 
+// RequesterFactoryFunc is an adapter to allow the use of ordinary functions
+// as RequesterFactories. The function is called for each Benchmark
+// connection, so per-connection state can be held in the closures of the
+// Requester it returns, typically a RequesterFuncs.
+type RequesterFactoryFunc func(number uint64) Requester
+
+// GetRequester calls f(number).
+func (f RequesterFactoryFunc) GetRequester(number uint64) Requester {
+	return f(number)
+}
+
+// RequesterFunc is an adapter to allow the use of an ordinary function as a
+// Requester which needs no setup or teardown.
+type RequesterFunc func() error
+
+// Setup does nothing.
+func (f RequesterFunc) Setup() error {
+	return nil
+}
+
+// Request calls f().
+func (f RequesterFunc) Request() error {
+	return f()
+}
+
+// Teardown does nothing.
+func (f RequesterFunc) Teardown() error {
+	return nil
+}
+
+// RequesterFuncs implements Requester with a function for each stage of the
+// Requester lifecycle. SetupFunc and TeardownFunc are optional.
+type RequesterFuncs struct {
+	SetupFunc    func() error
+	RequestFunc  func() error
+	TeardownFunc func() error
+}
+
+// Setup calls SetupFunc, if set.
+func (r *RequesterFuncs) Setup() error {
+	if r.SetupFunc == nil {
+		return nil
+	}
+	return r.SetupFunc()
+}
+
+// Request calls RequestFunc.
+func (r *RequesterFuncs) Request() error {
+	return r.RequestFunc()
+}
+
+// Teardown calls TeardownFunc, if set.
+func (r *RequesterFuncs) Teardown() error {
+	if r.TeardownFunc == nil {
+		return nil
+	}
+	return r.TeardownFunc()
+}
+
 // Benchmark performs a system benchmark by attempting to issue requests at a
 // specified rate and capturing the latency distribution. The request rate is
 // divided across the number of configured connections.
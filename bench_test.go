@@ -0,0 +1,166 @@
+package bench
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lifecycle counts the calls made to each connection's Requester.
+type lifecycle struct {
+	mu        sync.Mutex
+	setups    map[uint64]int
+	requests  map[uint64]int
+	teardowns map[uint64]int
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		setups:    make(map[uint64]int),
+		requests:  make(map[uint64]int),
+		teardowns: make(map[uint64]int),
+	}
+}
+
+func (l *lifecycle) inc(calls map[uint64]int, number uint64) {
+	l.mu.Lock()
+	calls[number]++
+	l.mu.Unlock()
+}
+
+func TestRequesterFactoryFunc(t *testing.T) {
+	const connections = 4
+	var (
+		calls = newLifecycle()
+		// Per-connection request counts as seen by each Requester's
+		// closures.
+		counted = make([]int, connections)
+	)
+	factory := RequesterFactoryFunc(func(number uint64) Requester {
+		var count int
+		return &RequesterFuncs{
+			SetupFunc: func() error {
+				calls.inc(calls.setups, number)
+				count = 0
+				return nil
+			},
+			RequestFunc: func() error {
+				calls.inc(calls.requests, number)
+				count++
+				return nil
+			},
+			TeardownFunc: func() error {
+				calls.inc(calls.teardowns, number)
+				counted[number] = count
+				return nil
+			},
+		}
+	})
+
+	summary, err := NewBenchmark(factory, 0, connections, 50*time.Millisecond, 0).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Connections != connections {
+		t.Fatalf("expected %d connections, got %d", connections, summary.Connections)
+	}
+
+	var total uint64
+	for i := uint64(0); i < connections; i++ {
+		if calls.setups[i] != 1 {
+			t.Errorf("expected 1 Setup for connection %d, got %d", i, calls.setups[i])
+		}
+		if calls.teardowns[i] != 1 {
+			t.Errorf("expected 1 Teardown for connection %d, got %d", i, calls.teardowns[i])
+		}
+		if calls.requests[i] == 0 {
+			t.Errorf("expected Requests for connection %d", i)
+		}
+		if counted[i] != calls.requests[i] {
+			t.Errorf("expected connection %d to count %d Requests, got %d",
+				i, calls.requests[i], counted[i])
+		}
+		total += uint64(calls.requests[i])
+	}
+	if summary.SuccessTotal != total {
+		t.Errorf("expected %d successful requests, got %d", total, summary.SuccessTotal)
+	}
+	if summary.ErrorTotal != 0 {
+		t.Errorf("expected no errors, got %d", summary.ErrorTotal)
+	}
+}
+
+func TestRequesterFuncsOptional(t *testing.T) {
+	r := &RequesterFuncs{RequestFunc: func() error { return nil }}
+	if err := r.Setup(); err != nil {
+		t.Fatalf("expected nil SetupFunc to succeed, got %v", err)
+	}
+	if err := r.Teardown(); err != nil {
+		t.Fatalf("expected nil TeardownFunc to succeed, got %v", err)
+	}
+
+	factory := RequesterFactoryFunc(func(uint64) Requester { return r })
+	summary, err := NewBenchmark(factory, 0, 2, 10*time.Millisecond, 0).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.SuccessTotal == 0 {
+		t.Fatal("expected successful requests")
+	}
+}
+
+func TestRequesterFuncsErrors(t *testing.T) {
+	setupErr := errors.New("setup failed")
+	factory := RequesterFactoryFunc(func(uint64) Requester {
+		return &RequesterFuncs{
+			SetupFunc:   func() error { return setupErr },
+			RequestFunc: func() error { return nil },
+		}
+	})
+	if _, err := NewBenchmark(factory, 0, 1, 10*time.Millisecond, 0).Run(); err != setupErr {
+		t.Fatalf("expected Setup error, got %v", err)
+	}
+
+	factory = RequesterFactoryFunc(func(uint64) Requester {
+		return &RequesterFuncs{
+			RequestFunc: func() error { return errors.New("request failed") },
+		}
+	})
+	summary, err := NewBenchmark(factory, 0, 1, 10*time.Millisecond, 0).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.SuccessTotal != 0 || summary.ErrorTotal == 0 {
+		t.Fatalf("expected only errors, got %d successes and %d errors",
+			summary.SuccessTotal, summary.ErrorTotal)
+	}
+}
+
+func TestRequesterFunc(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	r := RequesterFunc(func() error {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		return nil
+	})
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	factory := RequesterFactoryFunc(func(uint64) Requester { return r })
+	summary, err := NewBenchmark(factory, 1000, 1, 50*time.Millisecond, 10).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.SuccessTotal != uint64(requests) {
+		t.Fatalf("expected %d successful requests, got %d", requests, summary.SuccessTotal)
+	}
+}
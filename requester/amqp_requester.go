@@ -3,31 +3,79 @@ package requester
 import (
 	"errors"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/codahale/hdrhistogram"
 	"github.com/streadway/amqp"
 	"github.com/tylertreat/bench"
 )
 
+// amqpConfirmBuffer is the number of publisher confirms buffered by the AMQP
+// Requester.
+const amqpConfirmBuffer = 64
+
 // AMQPRequesterFactory implements RequesterFactory by creating a Requester
 // which publishes messages to an AMQP exchange and waits to consume them.
+//
+// ExchangeType defaults to "fanout". Messages are published with the queue
+// name as routing key, so "direct" and "topic" exchanges route them to the
+// bound queue as well. If Durable is set, the queue and exchange are declared
+// durable and the queue is not exclusive. If Persistent is set, messages are
+// published with persistent delivery mode. If Confirm is set, the channel is
+// put in confirm mode, each Request also waits for the publisher confirm, and
+// the confirm latency is recorded in ConfirmHistogram. If ManualAck is set,
+// deliveries are acknowledged explicitly rather than automatically. Prefetch
+// sets the consumer's prefetch count.
 type AMQPRequesterFactory struct {
-	URL         string
-	PayloadSize int
-	Queue       string
-	Exchange    string
+	URL          string
+	PayloadSize  int
+	Queue        string
+	Exchange     string
+	ExchangeType string
+	Durable      bool
+	Persistent   bool
+	Confirm      bool
+	ManualAck    bool
+	Prefetch     int
+
+	mu         sync.Mutex
+	histograms []*latencyHistogram
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (r *AMQPRequesterFactory) GetRequester(num uint64) bench.Requester {
+	exchangeType := r.ExchangeType
+	if exchangeType == "" {
+		exchangeType = "fanout"
+	}
+	confirms := newLatencyHistogram()
+	r.mu.Lock()
+	r.histograms = append(r.histograms, confirms)
+	r.mu.Unlock()
 	return &amqpRequester{
 		url:          r.URL,
 		payloadSize:  r.PayloadSize,
 		queueName:    r.Queue + "-" + strconv.FormatUint(num, 10),
 		exchangeName: r.Exchange + "-" + strconv.FormatUint(num, 10),
+		exchangeType: exchangeType,
+		durable:      r.Durable,
+		persistent:   r.Persistent,
+		confirm:      r.Confirm,
+		manualAck:    r.ManualAck,
+		prefetch:     r.Prefetch,
+		confirms:     confirms,
 	}
 }
 
+// ConfirmHistogram returns the distribution of publisher confirm latencies,
+// in nanoseconds, across every connection. It's empty unless Confirm is set.
+func (r *AMQPRequesterFactory) ConfirmHistogram() *hdrhistogram.Histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return mergeLatencyHistograms(r.histograms)
+}
+
 // amqpRequester implements Requester by publishing a message to an AMQP
 // exhcnage and waiting to consume it.
 type amqpRequester struct {
@@ -35,11 +83,21 @@ type amqpRequester struct {
 	payloadSize  int
 	queueName    string
 	exchangeName string
+	exchangeType string
+	durable      bool
+	persistent   bool
+	confirm      bool
+	manualAck    bool
+	prefetch     int
+	confirms     *latencyHistogram
 	conn         *amqp.Connection
 	queue        amqp.Queue
 	channel      *amqp.Channel
 	inbound      <-chan amqp.Delivery
+	published    chan amqp.Confirmation
 	msg          amqp.Publishing
+	idPrefix     string
+	seq          uint64
 }
 
 // Setup prepares the Requester for benchmarking.
@@ -54,9 +112,9 @@ func (r *amqpRequester) Setup() error {
 	}
 	queue, err := c.QueueDeclare(
 		r.queueName, // name
-		false,       // not durable
+		r.durable,   // durable
 		false,       // delete when unused
-		true,        // exclusive
+		!r.durable,  // exclusive
 		false,       // no wait
 		nil,         // arguments
 	)
@@ -65,8 +123,8 @@ func (r *amqpRequester) Setup() error {
 	}
 	err = c.ExchangeDeclare(
 		r.exchangeName, // name
-		"fanout",       // type
-		false,          // not durable
+		r.exchangeType, // type
+		r.durable,      // durable
 		false,          // auto-deleted
 		false,          // internal
 		false,          // no wait
@@ -85,25 +143,46 @@ func (r *amqpRequester) Setup() error {
 	if err != nil {
 		return err
 	}
+	if r.prefetch > 0 {
+		if err := c.Qos(r.prefetch, 0, false); err != nil {
+			return err
+		}
+	}
+	if r.confirm {
+		if err := c.Confirm(false); err != nil {
+			return err
+		}
+		// Confirms for requests which timed out are only drained by later
+		// requests, so buffer enough of them not to block the connection.
+		r.published = c.NotifyPublish(make(chan amqp.Confirmation, amqpConfirmBuffer))
+	}
 
 	inbound, err := c.Consume(
-		r.queueName, // queue
-		"",          // consumer
-		true,        // auto ack
-		false,       // exclusive
-		true,        // no local
-		false,       // no wait
-		nil,         // args
+		r.queueName,  // queue
+		"",           // consumer
+		!r.manualAck, // auto ack
+		false,        // exclusive
+		true,         // no local
+		false,        // no wait
+		nil,          // args
 	)
 	if err != nil {
 		return err
 	}
+	deliveryMode := amqp.Transient
+	if r.persistent {
+		deliveryMode = amqp.Persistent
+	}
 	r.conn = conn
 	r.queue = queue
 	r.channel = c
 	r.inbound = inbound
+	// The Setup time identifies this run of the connection in message ids,
+	// since a durable queue may hold messages left over from earlier runs.
+	r.idPrefix = strconv.FormatInt(time.Now().UnixNano(), 36) + "-"
+	r.seq = 0
 	r.msg = amqp.Publishing{
-		DeliveryMode: amqp.Transient,
+		DeliveryMode: deliveryMode,
 		Timestamp:    time.Now(),
 		ContentType:  "text/plain",
 		Body:         make([]byte, r.payloadSize),
@@ -113,19 +192,55 @@ func (r *amqpRequester) Setup() error {
 
 // Request performs a synchronous request to the system under test.
 func (r *amqpRequester) Request() error {
+	// In confirm mode the publish sequence number, starting at 1, is the
+	// delivery tag of the confirm. Together with the run's nonce it also
+	// identifies the delivery.
+	r.seq++
+	id := r.idPrefix + strconv.FormatUint(r.seq, 10)
+	msg := r.msg
+	msg.MessageId = id
+	start := time.Now()
 	if err := r.channel.Publish(
 		r.exchangeName, // exchange
-		"",             // routing key
+		r.queueName,    // routing key
 		false,          // mandatory
 		false,          // immediate
-		r.msg,
+		msg,
 	); err != nil {
 		return err
 	}
-	select {
-	case <-r.inbound:
-	case <-time.After(30 * time.Second):
-		return errors.New("requester: Request timed out receiving")
+
+	// Wait for the delivery and, in confirm mode, the publisher confirm,
+	// which may arrive in either order. Deliveries and confirms of earlier
+	// requests which timed out are skipped.
+	var (
+		delivered bool
+		confirmed = !r.confirm
+		timeout   = time.After(30 * time.Second)
+	)
+	for !delivered || !confirmed {
+		select {
+		case d := <-r.inbound:
+			if r.manualAck {
+				if err := d.Ack(false); err != nil {
+					return err
+				}
+			}
+			if d.MessageId == id {
+				delivered = true
+			}
+		case c := <-r.published:
+			if c.DeliveryTag != r.seq {
+				continue
+			}
+			if !c.Ack {
+				return errors.New("requester: message was nacked")
+			}
+			r.confirms.record(start)
+			confirmed = true
+		case <-timeout:
+			return errors.New("requester: Request timed out receiving")
+		}
 	}
 	return nil
 }
@@ -7,12 +7,25 @@ import (
 
 // CassandraRequesterFactory implements RequesterFactory by creating a
 // Requester which issues queries to Cassandra. Works with Cassandra 2.x.x.
+//
+// By default every request binds the same Values. If Generators is set, a
+// fresh value is generated for each bind marker on every request instead, so
+// that requests are spread across partitions. If PageSize is set, it's used
+// as the query page size, and if DrainPages is set, every page of the result
+// is fetched before the request completes. If BatchSize is greater than one,
+// each request executes a batch of BatchType containing BatchSize copies of
+// the statement, each bound with its own generated values.
 type CassandraRequesterFactory struct {
 	URLs        []string
 	Keyspace    string
 	Consistency gocql.Consistency
 	Statement   string
 	Values      []interface{}
+	Generators  []Generator
+	PageSize    int
+	DrainPages  bool
+	BatchSize   int
+	BatchType   gocql.BatchType
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
@@ -23,6 +36,11 @@ func (c *CassandraRequesterFactory) GetRequester(uint64) bench.Requester {
 		consistency: c.Consistency,
 		statement:   c.Statement,
 		values:      c.Values,
+		generators:  c.Generators,
+		pageSize:    c.PageSize,
+		drainPages:  c.DrainPages,
+		batchSize:   c.BatchSize,
+		batchType:   c.BatchType,
 	}
 }
 
@@ -34,7 +52,13 @@ type cassandraRequester struct {
 	consistency gocql.Consistency
 	statement   string
 	values      []interface{}
+	generators  []Generator
+	pageSize    int
+	drainPages  bool
+	batchSize   int
+	batchType   gocql.BatchType
 	session     *gocql.Session
+	query       *gocql.Query
 }
 
 // Setup prepares the Requester for benchmarking.
@@ -47,17 +71,51 @@ func (c *cassandraRequester) Setup() error {
 		return err
 	}
 	c.session = session
+
+	// The query is built once and rebound on each request. gocql prepares
+	// statements with bind markers on first use and reuses the prepared
+	// statement thereafter.
+	c.query = session.Query(c.statement, c.values...)
+	if c.pageSize > 0 {
+		c.query.PageSize(c.pageSize)
+	}
 	return nil
 }
 
 // Request performs a synchronous request to the system under test.
 func (c *cassandraRequester) Request() error {
-	return c.session.Query(c.statement, c.values...).Exec()
+	if c.batchSize > 1 {
+		batch := c.session.NewBatch(c.batchType)
+		for i := 0; i < c.batchSize; i++ {
+			batch.Query(c.statement, c.bindValues()...)
+		}
+		return c.session.ExecuteBatch(batch)
+	}
+
+	c.query.Bind(c.bindValues()...)
+	if !c.drainPages {
+		return c.query.Exec()
+	}
+	iter := c.query.Iter()
+	row := make(map[string]interface{})
+	for iter.MapScan(row) {
+		row = make(map[string]interface{})
+	}
+	return iter.Close()
+}
+
+// bindValues returns the values to bind for the next statement.
+func (c *cassandraRequester) bindValues() []interface{} {
+	if c.generators == nil {
+		return c.values
+	}
+	return generate(c.generators)
 }
 
 // Teardown is called upon benchmark completion.
 func (c *cassandraRequester) Teardown() error {
 	c.session.Close()
 	c.session = nil
+	c.query = nil
 	return nil
 }
@@ -0,0 +1,230 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"github.com/tylertreat/bench"
+)
+
+// CommandRequesterFactory implements RequesterFactory by creating a Requester
+// which runs an external command.
+//
+// By default each request runs Path with Args to completion, writing the
+// output of Input, if set, to its stdin. The request succeeds if the command
+// exits with ExitCode and, if Match is set, its stdout matches Match.
+//
+// If Persistent is set, each connection instead starts a single long-lived
+// process in Setup and, for each request, writes the output of Input as a
+// line to its stdin and reads a line back from its stdout, which must match
+// Match if set. If the process doesn't respond within Timeout it's killed
+// and restarted by the next request.
+//
+// Timeout bounds each request and defaults to 30 seconds.
+type CommandRequesterFactory struct {
+	Path       string
+	Args       []string
+	Env        []string
+	Dir        string
+	Input      Generator
+	Persistent bool
+	ExitCode   int
+	Match      *regexp.Regexp
+	Timeout    time.Duration
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (c *CommandRequesterFactory) GetRequester(uint64) bench.Requester {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return &commandRequester{
+		path:       c.Path,
+		args:       c.Args,
+		env:        c.Env,
+		dir:        c.Dir,
+		input:      c.Input,
+		persistent: c.Persistent,
+		exitCode:   c.ExitCode,
+		match:      c.Match,
+		timeout:    timeout,
+	}
+}
+
+// commandRequester implements Requester by running an external command, or
+// by exchanging a line with a long-lived process.
+type commandRequester struct {
+	path       string
+	args       []string
+	env        []string
+	dir        string
+	input      Generator
+	persistent bool
+	exitCode   int
+	match      *regexp.Regexp
+	timeout    time.Duration
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	lines      chan []byte
+	exited     chan struct{}
+}
+
+// Setup prepares the Requester for benchmarking.
+func (c *commandRequester) Setup() error {
+	if _, err := exec.LookPath(c.path); err != nil {
+		return err
+	}
+	if c.persistent {
+		if c.input == nil {
+			return errors.New("requester: Persistent command requires Input")
+		}
+		return c.start()
+	}
+	return nil
+}
+
+// command returns the command to run with the configured environment.
+func (c *commandRequester) command(ctx context.Context) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, c.path, c.args...)
+	cmd.Env = c.env
+	cmd.Dir = c.dir
+	return cmd
+}
+
+// start starts the long-lived process and a goroutine reading lines from its
+// stdout.
+func (c *commandRequester) start() error {
+	cmd := c.command(context.Background())
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	lines := make(chan []byte)
+	exited := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-exited:
+				return
+			}
+		}
+		close(lines)
+	}()
+	c.cmd = cmd
+	c.stdin = stdin
+	c.lines = lines
+	c.exited = exited
+	return nil
+}
+
+// stop kills the long-lived process and waits for it to exit.
+func (c *commandRequester) stop() {
+	if c.cmd == nil {
+		return
+	}
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	close(c.exited)
+	// Wait closes stdout, which unblocks the reading goroutine.
+	c.cmd.Wait()
+	c.cmd = nil
+	c.stdin = nil
+	c.lines = nil
+	c.exited = nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (c *commandRequester) Request() error {
+	if c.persistent {
+		return c.exchange()
+	}
+	return c.run()
+}
+
+// run runs the command to completion and checks its exit code and output.
+func (c *commandRequester) run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	cmd := c.command(ctx)
+	if c.input != nil {
+		cmd.Stdin = bytes.NewBufferString(fmt.Sprint(c.input()))
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("requester: Request timed out receiving")
+	}
+	exitCode := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return err
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	if exitCode != c.exitCode {
+		return fmt.Errorf("requester: command exited with code %d", exitCode)
+	}
+	return c.check(stdout.Bytes())
+}
+
+// exchange writes a line to the long-lived process and reads a line back.
+func (c *commandRequester) exchange() error {
+	if c.cmd == nil {
+		if err := c.start(); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(c.stdin, c.input()); err != nil {
+		c.stop()
+		return err
+	}
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case line, ok := <-c.lines:
+		if !ok {
+			c.stop()
+			return errors.New("requester: command exited")
+		}
+		return c.check(line)
+	case <-timer.C:
+		// Restart the process so a late response isn't read by the next
+		// request.
+		c.stop()
+		return errors.New("requester: Request timed out receiving")
+	}
+}
+
+// check returns an error if output doesn't match the expected pattern.
+func (c *commandRequester) check(output []byte) error {
+	if c.match != nil && !c.match.Match(output) {
+		return fmt.Errorf("requester: command output did not match %q", c.match)
+	}
+	return nil
+}
+
+// Teardown is called upon benchmark completion.
+func (c *commandRequester) Teardown() error {
+	c.stop()
+	return nil
+}
@@ -0,0 +1,115 @@
+package requester
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/tylertreat/bench"
+)
+
+// ConsulRequesterFactory implements RequesterFactory by creating a Requester
+// which puts or gets keys in the Consul KV store. Keys generates the key for
+// each request, prefixed with KeyPrefix, and defaults to sequential keys.
+// ReadRatio is the fraction of requests which are gets, the remainder being
+// puts of a random ValueSize-byte value. Gets use Consul's default
+// consistency mode unless Consistent is set, making them linearizable, or
+// Stale is set, allowing any server to answer. Consistent and Stale are
+// mutually exclusive.
+type ConsulRequesterFactory struct {
+	URL        string
+	Token      string
+	Keys       Generator
+	KeyPrefix  string
+	ReadRatio  float64
+	ValueSize  int
+	Consistent bool
+	Stale      bool
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (c *ConsulRequesterFactory) GetRequester(uint64) bench.Requester {
+	keys := c.Keys
+	if keys == nil {
+		keys = Sequential(0)
+	}
+	return &consulRequester{
+		url:        c.URL,
+		token:      c.Token,
+		keys:       keys,
+		keyPrefix:  c.KeyPrefix,
+		readRatio:  c.ReadRatio,
+		valueSize:  c.ValueSize,
+		consistent: c.Consistent,
+		stale:      c.Stale,
+	}
+}
+
+// consulRequester implements Requester by putting or getting a key in the
+// Consul KV store.
+type consulRequester struct {
+	url        string
+	token      string
+	keys       Generator
+	keyPrefix  string
+	readRatio  float64
+	valueSize  int
+	consistent bool
+	stale      bool
+	kv         *api.KV
+	value      []byte
+	queryOpts  *api.QueryOptions
+}
+
+// Setup prepares the Requester for benchmarking.
+func (c *consulRequester) Setup() error {
+	if c.consistent && c.stale {
+		return errors.New("requester: Consul Consistent and Stale are mutually exclusive")
+	}
+	config := api.DefaultConfig()
+	if c.url != "" {
+		config.Address = c.url
+	}
+	config.Token = c.token
+	httpClient, err := api.NewHttpClient(config.Transport, config.TLSConfig)
+	if err != nil {
+		return err
+	}
+	// Don't let a stalled agent block a Request indefinitely.
+	httpClient.Timeout = 30 * time.Second
+	config.HttpClient = httpClient
+	client, err := api.NewClient(config)
+	if err != nil {
+		return err
+	}
+	// Fail fast if the agent isn't reachable.
+	if _, err := client.Status().Leader(); err != nil {
+		return err
+	}
+	c.kv = client.KV()
+	c.value = newPayload(nil, c.valueSize)
+	c.queryOpts = &api.QueryOptions{
+		RequireConsistent: c.consistent,
+		AllowStale:        c.stale,
+	}
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (c *consulRequester) Request() error {
+	key := fmt.Sprint(c.keyPrefix, c.keys())
+	if rand.Float64() < c.readRatio {
+		_, _, err := c.kv.Get(key, c.queryOpts)
+		return err
+	}
+	_, err := c.kv.Put(&api.KVPair{Key: key, Value: c.value}, nil)
+	return err
+}
+
+// Teardown is called upon benchmark completion.
+func (c *consulRequester) Teardown() error {
+	c.kv = nil
+	return nil
+}
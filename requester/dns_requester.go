@@ -0,0 +1,129 @@
+package requester
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/tylertreat/bench"
+)
+
+// DNSRequesterFactory implements RequesterFactory by creating a Requester
+// which sends queries to a DNS server and waits for the response.
+//
+// Network is "udp" (the default), "tcp" or "tcp-tls" for DNS over TLS, in
+// which case TLSConfig is used. Each connection keeps its own connection to
+// Addr, redialing after an error. Names generates the name to query for each
+// request, for example RandomSubdomain to defeat caching, and QueryType
+// defaults to dns.TypeA. Responses with an rcode other than NOERROR are
+// treated as errors unless the rcode is listed in AcceptRcodes, e.g.
+// dns.RcodeNameError when querying random subdomains.
+type DNSRequesterFactory struct {
+	Addr         string
+	Network      string
+	TLSConfig    *tls.Config
+	Names        Generator
+	QueryType    uint16
+	AcceptRcodes []int
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (d *DNSRequesterFactory) GetRequester(uint64) bench.Requester {
+	queryType := d.QueryType
+	if queryType == 0 {
+		queryType = dns.TypeA
+	}
+	accept := map[int]bool{dns.RcodeSuccess: true}
+	for _, rcode := range d.AcceptRcodes {
+		accept[rcode] = true
+	}
+	return &dnsRequester{
+		addr:      d.Addr,
+		network:   d.Network,
+		tlsConfig: d.TLSConfig,
+		names:     d.Names,
+		queryType: queryType,
+		accept:    accept,
+	}
+}
+
+// RandomSubdomain returns a Generator which produces names made of a random
+// label of the given size under zone, e.g. "qzxjwkeh.example.com.".
+func RandomSubdomain(zone string, size int) Generator {
+	zone = dns.Fqdn(zone)
+	return func() interface{} {
+		return strings.ToLower(string(newPayload(nil, size))) + "." + zone
+	}
+}
+
+// dnsRequester implements Requester by sending a query to a DNS server and
+// waiting for the response.
+type dnsRequester struct {
+	addr      string
+	network   string
+	tlsConfig *tls.Config
+	names     Generator
+	queryType uint16
+	accept    map[int]bool
+	client    *dns.Client
+	conn      *dns.Conn
+	msg       *dns.Msg
+}
+
+// Setup prepares the Requester for benchmarking.
+func (d *dnsRequester) Setup() error {
+	if d.names == nil {
+		return errors.New("requester: DNS Names generator is required")
+	}
+	d.client = &dns.Client{
+		Net:       d.network,
+		TLSConfig: d.tlsConfig,
+		Timeout:   30 * time.Second,
+	}
+	conn, err := d.client.Dial(d.addr)
+	if err != nil {
+		return err
+	}
+	d.conn = conn
+	d.msg = new(dns.Msg)
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (d *dnsRequester) Request() error {
+	if d.conn == nil {
+		conn, err := d.client.Dial(d.addr)
+		if err != nil {
+			return err
+		}
+		d.conn = conn
+	}
+	d.msg.SetQuestion(dns.Fqdn(fmt.Sprint(d.names())), d.queryType)
+	resp, _, err := d.client.ExchangeWithConn(d.msg, d.conn)
+	if err != nil {
+		// The connection may hold a late response or be broken, so start
+		// afresh with the next request.
+		d.conn.Close()
+		d.conn = nil
+		return timeoutError(err)
+	}
+	if !d.accept[resp.Rcode] {
+		return fmt.Errorf("requester: DNS query for %s returned %s",
+			d.msg.Question[0].Name, dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// Teardown is called upon benchmark completion.
+func (d *dnsRequester) Teardown() error {
+	var err error
+	if d.conn != nil {
+		err = d.conn.Close()
+	}
+	d.conn = nil
+	d.client = nil
+	return err
+}
@@ -0,0 +1,214 @@
+package requester
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tylertreat/bench"
+)
+
+// ElasticsearchOperation is an operation performed by the Elasticsearch
+// Requester.
+type ElasticsearchOperation string
+
+// Elasticsearch operations.
+const (
+	// ElasticsearchIndex indexes a single Document.
+	ElasticsearchIndex ElasticsearchOperation = "index"
+
+	// ElasticsearchBulk indexes BulkSize Documents with the bulk API.
+	ElasticsearchBulk ElasticsearchOperation = "bulk"
+
+	// ElasticsearchSearch runs the Query against the index.
+	ElasticsearchSearch ElasticsearchOperation = "search"
+)
+
+// ElasticsearchRequesterFactory implements RequesterFactory by creating a
+// Requester which issues requests to an Elasticsearch-compatible REST API,
+// such as Elasticsearch or OpenSearch.
+//
+// Document generates each document to index and must produce a value which
+// can be marshaled to JSON. Query is a text/template for the search request
+// body, executed for each request with a map of values produced by
+// QueryFields. Values should be inserted with the json function, which
+// encodes them as JSON, e.g. `{"query": {"term": {"user": {{json .user}}}}}`,
+// so that strings are quoted and escaped. Refresh sets the refresh policy of
+// index and bulk requests: "true", "false" or "wait_for". Responses with an
+// error status, or bulk responses reporting item errors, are treated as
+// errors.
+type ElasticsearchRequesterFactory struct {
+	URL         string
+	Username    string
+	Password    string
+	Index       string
+	Operation   ElasticsearchOperation
+	Document    Generator
+	BulkSize    int
+	Query       string
+	QueryFields map[string]Generator
+	Refresh     string
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (e *ElasticsearchRequesterFactory) GetRequester(uint64) bench.Requester {
+	bulkSize := e.BulkSize
+	if bulkSize < 1 {
+		bulkSize = 1
+	}
+	return &elasticsearchRequester{
+		url:         strings.TrimSuffix(e.URL, "/"),
+		username:    e.Username,
+		password:    e.Password,
+		index:       e.Index,
+		operation:   e.Operation,
+		document:    e.Document,
+		bulkSize:    bulkSize,
+		query:       e.Query,
+		queryFields: e.QueryFields,
+		refresh:     e.Refresh,
+	}
+}
+
+// elasticsearchRequester implements Requester by issuing a request to an
+// Elasticsearch-compatible REST API.
+type elasticsearchRequester struct {
+	url           string
+	username      string
+	password      string
+	index         string
+	operation     ElasticsearchOperation
+	document      Generator
+	bulkSize      int
+	query         string
+	queryFields   map[string]Generator
+	refresh       string
+	client        *http.Client
+	endpoint      string
+	queryTemplate *template.Template
+	bulkAction    []byte
+	body          bytes.Buffer
+}
+
+// Setup prepares the Requester for benchmarking.
+func (e *elasticsearchRequester) Setup() error {
+	switch e.operation {
+	case ElasticsearchIndex:
+		if e.document == nil {
+			return errors.New("requester: Elasticsearch index requires a Document generator")
+		}
+		e.endpoint = e.url + "/" + e.index + "/_doc"
+	case ElasticsearchBulk:
+		if e.document == nil {
+			return errors.New("requester: Elasticsearch bulk requires a Document generator")
+		}
+		action, err := json.Marshal(map[string]interface{}{
+			"index": map[string]string{"_index": e.index},
+		})
+		if err != nil {
+			return err
+		}
+		e.bulkAction = append(action, '\n')
+		e.endpoint = e.url + "/_bulk"
+	case ElasticsearchSearch:
+		tmpl, err := template.New("query").Funcs(template.FuncMap{
+			"json": templateJSON,
+		}).Parse(e.query)
+		if err != nil {
+			return err
+		}
+		e.queryTemplate = tmpl
+		e.endpoint = e.url + "/" + e.index + "/_search"
+	default:
+		return fmt.Errorf("requester: unknown Elasticsearch operation %q", e.operation)
+	}
+	if e.refresh != "" && e.operation != ElasticsearchSearch {
+		e.endpoint += "?" + url.Values{"refresh": {e.refresh}}.Encode()
+	}
+	e.client = &http.Client{Timeout: 30 * time.Second}
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (e *elasticsearchRequester) Request() error {
+	e.body.Reset()
+	contentType := "application/json"
+	switch e.operation {
+	case ElasticsearchIndex:
+		if err := json.NewEncoder(&e.body).Encode(e.document()); err != nil {
+			return err
+		}
+	case ElasticsearchBulk:
+		contentType = "application/x-ndjson"
+		enc := json.NewEncoder(&e.body)
+		for i := 0; i < e.bulkSize; i++ {
+			e.body.Write(e.bulkAction)
+			if err := enc.Encode(e.document()); err != nil {
+				return err
+			}
+		}
+	case ElasticsearchSearch:
+		fields := make(map[string]interface{}, len(e.queryFields))
+		for k, g := range e.queryFields {
+			fields[k] = g()
+		}
+		if err := e.queryTemplate.Execute(&e.body, fields); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest("POST", e.endpoint, &e.body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if e.username != "" {
+		req.SetBasicAuth(e.username, e.password)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return fmt.Errorf("requester: Elasticsearch returned %s", resp.Status)
+	}
+	if e.operation != ElasticsearchBulk {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+	var bulk struct {
+		Errors bool `json:"errors"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&bulk)
+	// Drain the body so that the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	if err != nil {
+		return err
+	}
+	if bulk.Errors {
+		return errors.New("requester: Elasticsearch bulk request had item errors")
+	}
+	return nil
+}
+
+// templateJSON encodes v as JSON for use in a query template.
+func templateJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Teardown is called upon benchmark completion.
+func (e *elasticsearchRequester) Teardown() error {
+	e.client = nil
+	return nil
+}
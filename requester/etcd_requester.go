@@ -0,0 +1,188 @@
+package requester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/tylertreat/bench"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// EtcdOperation is an operation performed by the etcd Requester.
+type EtcdOperation string
+
+// etcd operations.
+const (
+	// EtcdPut puts a value.
+	EtcdPut EtcdOperation = "put"
+
+	// EtcdGet gets a value.
+	EtcdGet EtcdOperation = "get"
+
+	// EtcdTxn runs a transaction which puts a value if the key doesn't exist
+	// and gets it otherwise.
+	EtcdTxn EtcdOperation = "txn"
+
+	// EtcdWatch puts a value to a per-connection key and waits for the
+	// corresponding watch event.
+	EtcdWatch EtcdOperation = "watch"
+)
+
+// EtcdRequesterFactory implements RequesterFactory by creating a Requester
+// which performs an operation against an etcd v3 cluster. Keys generates the
+// key for each request, prefixed with KeyPrefix, and defaults to sequential
+// keys. Values are random and ValueSize bytes long. Gets are linearizable
+// unless Serializable is set, in which case they may be served by any member
+// without consensus.
+type EtcdRequesterFactory struct {
+	URLs         []string
+	Operation    EtcdOperation
+	Keys         Generator
+	KeyPrefix    string
+	ValueSize    int
+	Serializable bool
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (e *EtcdRequesterFactory) GetRequester(num uint64) bench.Requester {
+	keys := e.Keys
+	if keys == nil {
+		keys = Sequential(0)
+	}
+	return &etcdRequester{
+		urls:         e.URLs,
+		operation:    e.Operation,
+		keys:         keys,
+		keyPrefix:    e.KeyPrefix,
+		valueSize:    e.ValueSize,
+		serializable: e.Serializable,
+		watchKey:     e.KeyPrefix + "watch-" + strconv.FormatUint(num, 10),
+	}
+}
+
+// etcdRequester implements Requester by performing an operation against an
+// etcd v3 cluster.
+type etcdRequester struct {
+	urls         []string
+	operation    EtcdOperation
+	keys         Generator
+	keyPrefix    string
+	valueSize    int
+	serializable bool
+	watchKey     string
+	client       *clientv3.Client
+	value        string
+	watch        clientv3.WatchChan
+	cancelWatch  context.CancelFunc
+}
+
+// Setup prepares the Requester for benchmarking.
+func (e *etcdRequester) Setup() error {
+	switch e.operation {
+	case EtcdPut, EtcdGet, EtcdTxn, EtcdWatch:
+	default:
+		return fmt.Errorf("requester: unknown etcd operation %q", e.operation)
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   e.urls,
+		DialTimeout: 30 * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	e.client = client
+	e.value = string(newPayload(nil, e.valueSize))
+	if e.operation == EtcdWatch {
+		// Watch from the current revision so that no events are missed
+		// while the watch is being established.
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		resp, err := client.Get(ctx, e.watchKey)
+		cancel()
+		if err != nil {
+			client.Close()
+			return err
+		}
+		ctx, cancel = context.WithCancel(context.Background())
+		e.watch = client.Watch(ctx, e.watchKey, clientv3.WithRev(resp.Header.Revision+1))
+		e.cancelWatch = cancel
+	}
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (e *etcdRequester) Request() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if e.operation == EtcdWatch {
+		return e.watchRoundTrip(ctx)
+	}
+
+	key := fmt.Sprint(e.keyPrefix, e.keys())
+	var err error
+	switch e.operation {
+	case EtcdPut:
+		_, err = e.client.Put(ctx, key, e.value)
+	case EtcdGet:
+		_, err = e.client.Get(ctx, key, e.readOptions()...)
+	case EtcdTxn:
+		_, err = e.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.Version(key), "=", 0)).
+			Then(clientv3.OpPut(key, e.value)).
+			Else(clientv3.OpGet(key, e.readOptions()...)).
+			Commit()
+	}
+	return err
+}
+
+// readOptions returns the options for reads.
+func (e *etcdRequester) readOptions() []clientv3.OpOption {
+	if e.serializable {
+		return []clientv3.OpOption{clientv3.WithSerializable()}
+	}
+	return nil
+}
+
+// watchRoundTrip puts a value to the watched key and waits for the event.
+func (e *etcdRequester) watchRoundTrip(ctx context.Context) error {
+	resp, err := e.client.Put(ctx, e.watchKey, e.value)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case event, ok := <-e.watch:
+			if !ok {
+				return errors.New("requester: etcd watch closed")
+			}
+			if err := event.Err(); err != nil {
+				return err
+			}
+			// Events for earlier requests which timed out are skipped.
+			for _, ev := range event.Events {
+				if ev.Kv.ModRevision >= resp.Header.Revision {
+					return nil
+				}
+			}
+		case <-ctx.Done():
+			return errors.New("requester: Request timed out receiving")
+		}
+	}
+}
+
+// Teardown is called upon benchmark completion.
+func (e *etcdRequester) Teardown() error {
+	if e.cancelWatch != nil {
+		e.cancelWatch()
+		e.cancelWatch = nil
+	}
+	if err := e.client.Close(); err != nil {
+		return err
+	}
+	e.client = nil
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build linux
+// +build linux
+
+package requester
+
+import "syscall"
+
+// directFlag is the flag opening a file for direct I/O.
+const directFlag = syscall.O_DIRECT
@@ -0,0 +1,7 @@
+//go:build !linux
+// +build !linux
+
+package requester
+
+// directFlag is zero as direct I/O isn't supported on this platform.
+const directFlag = 0
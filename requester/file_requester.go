@@ -0,0 +1,204 @@
+package requester
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"unsafe"
+
+	"github.com/tylertreat/bench"
+)
+
+// directAlignment is the alignment of buffers used for direct I/O, which
+// satisfies the logical block size of common devices.
+const directAlignment = 4096
+
+// FileOperation is an operation performed by the File Requester.
+type FileOperation string
+
+// File operations.
+const (
+	// FileRead reads a block from the file.
+	FileRead FileOperation = "read"
+
+	// FileWrite writes a block to the file.
+	FileWrite FileOperation = "write"
+
+	// FileFsync writes a block to the file and fsyncs it.
+	FileFsync FileOperation = "fsync"
+
+	// FileOpenClose opens and closes the file.
+	FileOpenClose FileOperation = "openclose"
+)
+
+// FileRequesterFactory implements RequesterFactory by creating a Requester
+// which performs I/O on a file. Each connection uses its own file with a
+// unique name in Dir, which defaults to the system's temporary directory,
+// created with FileSize bytes in Setup and removed in Teardown. Reads and
+// writes are BlockSize bytes at offsets which are sequential, wrapping at the
+// end of the file, or random block-aligned offsets if Random is set. If Direct
+// is set, the file is opened with O_DIRECT to bypass the page cache, in which
+// case BlockSize must be a multiple of the device's block size. Direct I/O is
+// only supported on Linux.
+type FileRequesterFactory struct {
+	Dir       string
+	Operation FileOperation
+	BlockSize int
+	FileSize  int64
+	Random    bool
+	Direct    bool
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (f *FileRequesterFactory) GetRequester(uint64) bench.Requester {
+	dir := f.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	blockSize := f.BlockSize
+	if blockSize <= 0 {
+		blockSize = 4096
+	}
+	fileSize := f.FileSize
+	if fileSize <= 0 {
+		fileSize = 64 << 20
+	}
+	return &fileRequester{
+		dir:       dir,
+		operation: f.Operation,
+		blockSize: blockSize,
+		blocks:    fileSize / int64(blockSize),
+		random:    f.Random,
+		direct:    f.Direct,
+	}
+}
+
+// fileRequester implements Requester by performing I/O on a file.
+type fileRequester struct {
+	dir       string
+	path      string
+	operation FileOperation
+	blockSize int
+	blocks    int64
+	random    bool
+	direct    bool
+	flag      int
+	file      *os.File
+	buf       []byte
+	block     int64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (f *fileRequester) Setup() error {
+	switch f.operation {
+	case FileRead, FileWrite, FileFsync, FileOpenClose:
+	default:
+		return fmt.Errorf("requester: unknown file operation %q", f.operation)
+	}
+	if f.blocks < 1 {
+		return errors.New("requester: FileSize is smaller than BlockSize")
+	}
+	f.flag = os.O_RDWR
+	if f.direct {
+		if directFlag == 0 {
+			return errors.New("requester: direct I/O is not supported on this platform")
+		}
+		f.flag |= directFlag
+	}
+	f.buf = alignedBuffer(f.blockSize)
+	copy(f.buf, newPayload(nil, f.blockSize))
+
+	if err := f.fill(); err != nil {
+		if f.path != "" {
+			os.Remove(f.path)
+		}
+		return err
+	}
+	file, err := os.OpenFile(f.path, f.flag, 0)
+	if err != nil {
+		os.Remove(f.path)
+		return err
+	}
+	f.file = file
+	f.block = 0
+	return nil
+}
+
+// fill creates a new file in the directory and writes every block so that
+// reads don't hit holes.
+func (f *fileRequester) fill() error {
+	file, err := ioutil.TempFile(f.dir, "bench-")
+	if err != nil {
+		f.path = ""
+		return err
+	}
+	f.path = file.Name()
+	for i := int64(0); i < f.blocks; i++ {
+		if _, err := file.Write(f.buf); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// offset returns the offset of the next block.
+func (f *fileRequester) offset() int64 {
+	if f.random {
+		return rand.Int63n(f.blocks) * int64(f.blockSize)
+	}
+	off := f.block * int64(f.blockSize)
+	f.block = (f.block + 1) % f.blocks
+	return off
+}
+
+// Request performs a synchronous request to the system under test.
+func (f *fileRequester) Request() error {
+	switch f.operation {
+	case FileRead:
+		_, err := f.file.ReadAt(f.buf, f.offset())
+		return err
+	case FileWrite:
+		_, err := f.file.WriteAt(f.buf, f.offset())
+		return err
+	case FileFsync:
+		if _, err := f.file.WriteAt(f.buf, f.offset()); err != nil {
+			return err
+		}
+		return f.file.Sync()
+	case FileOpenClose:
+		file, err := os.OpenFile(f.path, f.flag, 0)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	}
+	return nil
+}
+
+// Teardown is called upon benchmark completion.
+func (f *fileRequester) Teardown() error {
+	err := f.file.Close()
+	if rerr := os.Remove(f.path); err == nil {
+		err = rerr
+	}
+	f.file = nil
+	return err
+}
+
+// alignedBuffer returns a buffer of the given size whose address is aligned
+// as required for direct I/O.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directAlignment)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & (directAlignment - 1)); rem != 0 {
+		off = directAlignment - rem
+	}
+	return buf[off : off+size : off+size]
+}
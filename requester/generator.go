@@ -0,0 +1,83 @@
+package requester
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Generator produces a value for each request, such as a key or a statement
+// argument. Generators may be shared by every connection of a Benchmark, so
+// they must be safe for concurrent use.
+type Generator func() interface{}
+
+// Constant returns a Generator which always produces v.
+func Constant(v interface{}) Generator {
+	return func() interface{} { return v }
+}
+
+// Sequential returns a Generator which produces int64s counting up from
+// start.
+func Sequential(start int64) Generator {
+	next := start - 1
+	return func() interface{} {
+		return atomic.AddInt64(&next, 1)
+	}
+}
+
+// RandomInt returns a Generator which produces uniformly distributed int64s in
+// [0, n).
+func RandomInt(n int64) Generator {
+	return func() interface{} {
+		return rand.Int63n(n)
+	}
+}
+
+// Zipf returns a Generator which produces Zipf-distributed int64s in
+// [0, imax], so that a small number of keys are hot as in most real
+// workloads. The parameters s > 1 and v >= 1 are as described for
+// rand.NewZipf. Zipf panics if they are out of range.
+func Zipf(s, v float64, imax uint64) Generator {
+	if s <= 1 || v < 1 {
+		panic(fmt.Sprintf("requester: Zipf requires s > 1 and v >= 1, got s=%v v=%v", s, v))
+	}
+	var (
+		mu   sync.Mutex
+		zipf = rand.NewZipf(rand.New(rand.NewSource(time.Now().UnixNano())), s, v, imax)
+	)
+	return func() interface{} {
+		mu.Lock()
+		n := zipf.Uint64()
+		mu.Unlock()
+		return int64(n)
+	}
+}
+
+// RandomBytes returns a Generator which produces random []byte values of the
+// given size.
+func RandomBytes(size int) Generator {
+	return func() interface{} {
+		b := make([]byte, size)
+		rand.Read(b)
+		return b
+	}
+}
+
+// RandomString returns a Generator which produces random strings of uppercase
+// letters of the given size.
+func RandomString(size int) Generator {
+	return func() interface{} {
+		return string(newPayload(nil, size))
+	}
+}
+
+// generate returns the next value of each Generator.
+func generate(generators []Generator) []interface{} {
+	values := make([]interface{}, len(generators))
+	for i, g := range generators {
+		values[i] = g()
+	}
+	return values
+}
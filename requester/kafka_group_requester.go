@@ -0,0 +1,269 @@
+package requester
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/tylertreat/bench"
+)
+
+// KafkaGroupRequesterFactory implements RequesterFactory by creating a
+// Requester which publishes keyed messages to a shared Kafka topic and waits
+// to consume them through a consumer group.
+//
+// Every connection produces to and consumes from the same Topic, which
+// should be created beforehand with the desired number of partitions. Each
+// message is keyed by its connection number, a nonce identifying the
+// connection's run and a sequence number, so the default hash partitioner
+// spreads messages across partitions and messages left over from earlier runs
+// are never mistaken for current ones. Each connection joins the consumer
+// Group, which defaults to "bench", and consumed messages are routed by key to
+// the connection which sent them so that latency is measured correctly
+// regardless of which partition or group member a message passes through. A
+// new Group starts consuming from the oldest offset, so the first run against
+// a Topic with existing messages skips over them before the benchmark's own
+// messages are received.
+type KafkaGroupRequesterFactory struct {
+	URLs         []string
+	PayloadSize  int
+	Topic        string
+	Group        string
+	RequiredAcks sarama.RequiredAcks
+
+	once       sync.Once
+	dispatcher *kafkaDispatcher
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (k *KafkaGroupRequesterFactory) GetRequester(num uint64) bench.Requester {
+	k.once.Do(func() {
+		k.dispatcher = &kafkaDispatcher{receivers: make(map[uint64]kafkaReceiver)}
+	})
+	group := k.Group
+	if group == "" {
+		group = "bench"
+	}
+	return &kafkaGroupRequester{
+		urls:         k.URLs,
+		payloadSize:  k.PayloadSize,
+		topic:        k.Topic,
+		group:        group,
+		requiredAcks: k.RequiredAcks,
+		num:          num,
+		dispatcher:   k.dispatcher,
+	}
+}
+
+// kafkaDispatcher routes consumed messages to the connection which sent
+// them, since any member of the consumer group may consume a given message.
+type kafkaDispatcher struct {
+	mu        sync.Mutex
+	receivers map[uint64]kafkaReceiver
+}
+
+// kafkaReceiver is a connection registered with a kafkaDispatcher.
+type kafkaReceiver struct {
+	nonce string
+	ch    chan string
+}
+
+// register returns the channel on which keys of messages sent by the given
+// connection with the given nonce are delivered.
+func (d *kafkaDispatcher) register(num uint64, nonce string) chan string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ch := make(chan string, 64)
+	d.receivers[num] = kafkaReceiver{nonce: nonce, ch: ch}
+	return ch
+}
+
+// unregister stops delivering messages to the given connection.
+func (d *kafkaDispatcher) unregister(num uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.receivers, num)
+}
+
+// dispatch delivers the key of a consumed message to the connection which
+// sent it. Keys have the form "num-nonce-seq". Messages for unknown
+// connections, from earlier runs, or for connections which are not keeping
+// up, are dropped.
+func (d *kafkaDispatcher) dispatch(key string) {
+	parts := strings.SplitN(key, "-", 3)
+	if len(parts) != 3 {
+		return
+	}
+	num, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	receiver, ok := d.receivers[num]
+	if !ok || receiver.nonce != parts[1] {
+		return
+	}
+	select {
+	case receiver.ch <- key:
+	default:
+	}
+}
+
+// kafkaGroupRequester implements Requester by publishing a keyed message to a
+// shared Kafka topic and waiting to consume it through a consumer group.
+type kafkaGroupRequester struct {
+	urls         []string
+	payloadSize  int
+	topic        string
+	group        string
+	requiredAcks sarama.RequiredAcks
+	num          uint64
+	dispatcher   *kafkaDispatcher
+	producer     sarama.AsyncProducer
+	consumer     sarama.ConsumerGroup
+	cancel       context.CancelFunc
+	done         chan struct{}
+	received     chan string
+	value        sarama.ByteEncoder
+	keyPrefix    string
+	seq          uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (k *kafkaGroupRequester) Setup() error {
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_10_2_0
+	if k.requiredAcks != 0 {
+		config.Producer.RequiredAcks = k.requiredAcks
+	}
+	config.Producer.Return.Errors = true
+	// Partition consumers only start after the group session is set up, so
+	// starting a new group from the newest offset would skip messages
+	// produced in between. Old messages are ignored by their keys instead.
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	producer, err := sarama.NewAsyncProducer(k.urls, config)
+	if err != nil {
+		return err
+	}
+	consumer, err := sarama.NewConsumerGroup(k.urls, k.group, config)
+	if err != nil {
+		producer.Close()
+		return err
+	}
+
+	k.producer = producer
+	k.consumer = consumer
+	// The Setup time identifies this run of the connection in message keys.
+	nonce := strconv.FormatInt(time.Now().UnixNano(), 36)
+	k.keyPrefix = strconv.FormatUint(k.num, 10) + "-" + nonce + "-"
+	k.seq = 0
+	k.received = k.dispatcher.register(k.num, nonce)
+	k.value = sarama.ByteEncoder(newPayload(nil, k.payloadSize))
+	k.done = make(chan struct{})
+
+	// Consume until Teardown, rejoining the group after each rebalance. Wait
+	// for the first session to be established.
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	handler := &kafkaGroupHandler{dispatcher: k.dispatcher, ready: make(chan struct{})}
+	errs := make(chan error, 1)
+	go func() {
+		defer close(k.done)
+		for ctx.Err() == nil {
+			if err := consumer.Consume(ctx, []string{k.topic}, handler); err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+				return
+			}
+		}
+	}()
+	select {
+	case <-handler.ready:
+		return nil
+	case err := <-errs:
+		k.Teardown()
+		return err
+	case <-time.After(30 * time.Second):
+		k.Teardown()
+		return errors.New("requester: Setup timed out joining consumer group")
+	}
+}
+
+// Request performs a synchronous request to the system under test.
+func (k *kafkaGroupRequester) Request() error {
+	k.seq++
+	key := k.keyPrefix + strconv.FormatUint(k.seq, 10)
+	k.producer.Input() <- &sarama.ProducerMessage{
+		Topic: k.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: k.value,
+	}
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case received := <-k.received:
+			// Messages from earlier requests which timed out are skipped.
+			if received == key {
+				return nil
+			}
+		case err := <-k.producer.Errors():
+			return err.Err
+		case <-timeout:
+			return errors.New("requester: Request timed out receiving")
+		}
+	}
+}
+
+// Teardown is called upon benchmark completion.
+func (k *kafkaGroupRequester) Teardown() error {
+	k.dispatcher.unregister(k.num)
+	k.cancel()
+	<-k.done
+	if err := k.consumer.Close(); err != nil {
+		return err
+	}
+	if err := k.producer.Close(); err != nil {
+		return err
+	}
+	k.consumer = nil
+	k.producer = nil
+	return nil
+}
+
+// kafkaGroupHandler implements sarama.ConsumerGroupHandler by dispatching
+// consumed messages to the connections which sent them.
+type kafkaGroupHandler struct {
+	dispatcher *kafkaDispatcher
+	ready      chan struct{}
+	once       sync.Once
+}
+
+// Setup is called at the start of each consumer group session.
+func (h *kafkaGroupHandler) Setup(sarama.ConsumerGroupSession) error {
+	h.once.Do(func() { close(h.ready) })
+	return nil
+}
+
+// Cleanup is called at the end of each consumer group session.
+func (h *kafkaGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim dispatches the messages of a single partition claim.
+func (h *kafkaGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim) error {
+
+	for msg := range claim.Messages() {
+		h.dispatcher.dispatch(string(msg.Key))
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
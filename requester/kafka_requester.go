@@ -12,18 +12,40 @@ import (
 
 // KafkaRequesterFactory implements RequesterFactory by creating a Requester
 // which publishes messages to Kafka and waits to consume them.
+//
+// RequiredAcks and Compression configure the producer. A zero RequiredAcks
+// uses sarama's default of sarama.WaitForLocal. Idempotent enables the
+// idempotent producer, which implies sarama.WaitForAll. Linger,
+// BatchMessages and BatchBytes control how the producer batches messages
+// before sending. If Sync is set, each message is produced with a
+// SyncProducer so that Request waits for the broker's acknowledgement before
+// consuming. Produce failures are returned as Request errors.
 type KafkaRequesterFactory struct {
-	URLs        []string
-	PayloadSize int
-	Topic       string
+	URLs          []string
+	PayloadSize   int
+	Topic         string
+	RequiredAcks  sarama.RequiredAcks
+	Compression   sarama.CompressionCodec
+	Idempotent    bool
+	Linger        time.Duration
+	BatchMessages int
+	BatchBytes    int
+	Sync          bool
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (k *KafkaRequesterFactory) GetRequester(num uint64) bench.Requester {
 	return &kafkaRequester{
-		urls:        k.URLs,
-		payloadSize: k.PayloadSize,
-		topic:       k.Topic + "-" + strconv.FormatUint(num, 10),
+		urls:          k.URLs,
+		payloadSize:   k.PayloadSize,
+		topic:         k.Topic + "-" + strconv.FormatUint(num, 10),
+		requiredAcks:  k.RequiredAcks,
+		compression:   k.Compression,
+		idempotent:    k.Idempotent,
+		linger:        k.Linger,
+		batchMessages: k.BatchMessages,
+		batchBytes:    k.BatchBytes,
+		sync:          k.Sync,
 	}
 }
 
@@ -33,7 +55,15 @@ type kafkaRequester struct {
 	urls              []string
 	payloadSize       int
 	topic             string
+	requiredAcks      sarama.RequiredAcks
+	compression       sarama.CompressionCodec
+	idempotent        bool
+	linger            time.Duration
+	batchMessages     int
+	batchBytes        int
+	sync              bool
 	producer          sarama.AsyncProducer
+	syncProducer      sarama.SyncProducer
 	consumer          sarama.Consumer
 	partitionConsumer sarama.PartitionConsumer
 	msg               *sarama.ProducerMessage
@@ -41,25 +71,42 @@ type kafkaRequester struct {
 
 // Setup prepares the Requester for benchmarking.
 func (k *kafkaRequester) Setup() error {
-	config := sarama.NewConfig()
-	producer, err := sarama.NewAsyncProducer(k.urls, config)
+	config := k.producerConfig()
+	var (
+		producer     sarama.AsyncProducer
+		syncProducer sarama.SyncProducer
+		err          error
+	)
+	if k.sync {
+		syncProducer, err = sarama.NewSyncProducer(k.urls, config)
+	} else {
+		producer, err = sarama.NewAsyncProducer(k.urls, config)
+	}
 	if err != nil {
 		return err
 	}
+	closeProducer := func() {
+		if k.sync {
+			syncProducer.Close()
+		} else {
+			producer.Close()
+		}
+	}
 
 	consumer, err := sarama.NewConsumer(k.urls, nil)
 	if err != nil {
-		producer.Close()
+		closeProducer()
 		return err
 	}
 	partitionConsumer, err := consumer.ConsumePartition(k.topic, 0, sarama.OffsetNewest)
 	if err != nil {
-		producer.Close()
+		closeProducer()
 		consumer.Close()
 		return err
 	}
 
 	k.producer = producer
+	k.syncProducer = syncProducer
 	k.consumer = consumer
 	k.partitionConsumer = partitionConsumer
 	msg := make([]byte, k.payloadSize)
@@ -74,17 +121,55 @@ func (k *kafkaRequester) Setup() error {
 	return nil
 }
 
+// producerConfig returns the sarama configuration for the producer.
+func (k *kafkaRequester) producerConfig() *sarama.Config {
+	config := sarama.NewConfig()
+	if k.requiredAcks != 0 {
+		config.Producer.RequiredAcks = k.requiredAcks
+	}
+	config.Producer.Compression = k.compression
+	if k.idempotent {
+		config.Version = sarama.V0_11_0_0
+		config.Producer.Idempotent = true
+		config.Producer.RequiredAcks = sarama.WaitForAll
+		config.Net.MaxOpenRequests = 1
+	}
+	config.Producer.Flush.Frequency = k.linger
+	config.Producer.Flush.Messages = k.batchMessages
+	config.Producer.Flush.Bytes = k.batchBytes
+	config.Producer.Return.Errors = true
+	config.Producer.Return.Successes = k.sync
+	return config
+}
+
 // Request performs a synchronous request to the system under test.
 func (k *kafkaRequester) Request() error {
-	k.producer.Input() <- k.msg
+	if k.sync {
+		if _, _, err := k.syncProducer.SendMessage(k.msg); err != nil {
+			return err
+		}
+	} else {
+		k.producer.Input() <- k.msg
+	}
 	select {
 	case <-k.partitionConsumer.Messages():
 		return nil
+	case err := <-k.producerErrors():
+		return err.Err
 	case <-time.After(30 * time.Second):
 		return errors.New("requester: Request timed out receiving")
 	}
 }
 
+// producerErrors returns the async producer's error channel, or nil when
+// producing synchronously since errors are then returned by SendMessage.
+func (k *kafkaRequester) producerErrors() <-chan *sarama.ProducerError {
+	if k.sync {
+		return nil
+	}
+	return k.producer.Errors()
+}
+
 // Teardown is called upon benchmark completion.
 func (k *kafkaRequester) Teardown() error {
 	if err := k.partitionConsumer.Close(); err != nil {
@@ -93,11 +178,16 @@ func (k *kafkaRequester) Teardown() error {
 	if err := k.consumer.Close(); err != nil {
 		return err
 	}
-	if err := k.producer.Close(); err != nil {
+	if k.sync {
+		if err := k.syncProducer.Close(); err != nil {
+			return err
+		}
+	} else if err := k.producer.Close(); err != nil {
 		return err
 	}
 	k.partitionConsumer = nil
 	k.consumer = nil
 	k.producer = nil
+	k.syncProducer = nil
 	return nil
 }
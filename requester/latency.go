@@ -0,0 +1,52 @@
+package requester
+
+import (
+	"sync"
+	"time"
+
+	"github.com/codahale/hdrhistogram"
+)
+
+const (
+	// maxRecordableLatencyNS is the largest latency which can be recorded by
+	// a latencyHistogram.
+	maxRecordableLatencyNS = 300000000000
+
+	// latencySigFigs is the precision of a latencyHistogram.
+	latencySigFigs = 3
+)
+
+// latencyHistogram records latencies, in nanoseconds, for a secondary
+// measurement of a Requester, such as a publish acknowledgement, that isn't
+// captured by the Benchmark itself. It's safe for concurrent use since such
+// measurements are often completed by client library callbacks.
+type latencyHistogram struct {
+	mu        sync.Mutex
+	histogram *hdrhistogram.Histogram
+}
+
+// newLatencyHistogram returns an empty latencyHistogram.
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{
+		histogram: hdrhistogram.New(1, maxRecordableLatencyNS, latencySigFigs),
+	}
+}
+
+// record the latency of an operation which started at the given time.
+func (l *latencyHistogram) record(start time.Time) {
+	l.mu.Lock()
+	l.histogram.RecordValue(time.Since(start).Nanoseconds())
+	l.mu.Unlock()
+}
+
+// mergeLatencyHistograms returns a histogram containing the latencies
+// recorded by each of the given latencyHistograms.
+func mergeLatencyHistograms(histograms []*latencyHistogram) *hdrhistogram.Histogram {
+	merged := hdrhistogram.New(1, maxRecordableLatencyNS, latencySigFigs)
+	for _, l := range histograms {
+		l.mu.Lock()
+		merged.Merge(l.histogram)
+		l.mu.Unlock()
+	}
+	return merged
+}
@@ -0,0 +1,295 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/tylertreat/bench"
+)
+
+// Memcached binary protocol constants.
+const (
+	memcachedRequestMagic  = 0x80
+	memcachedResponseMagic = 0x81
+	memcachedHeaderSize    = 24
+
+	memcachedOpGet   = 0x00
+	memcachedOpSet   = 0x01
+	memcachedOpNoop  = 0x0a
+	memcachedOpGetKQ = 0x0d
+
+	memcachedStatusOK          = 0x00
+	memcachedStatusKeyNotFound = 0x01
+)
+
+// MemcachedRequesterFactory implements RequesterFactory by creating a
+// Requester which sends get and set commands to Memcached and waits for the
+// reply. Keys generates the key for each command, prefixed with KeyPrefix,
+// and defaults to sequential keys. ReadRatio is the fraction of requests
+// which are gets, the remainder being sets of a random ValueSize-byte value.
+// If MultiGet is greater than one, each get fetches that many keys at once.
+// If Binary is set, the binary protocol is used instead of the text
+// protocol. Cache misses are not treated as errors.
+type MemcachedRequesterFactory struct {
+	URL       string
+	Binary    bool
+	Keys      Generator
+	KeyPrefix string
+	ReadRatio float64
+	ValueSize int
+	MultiGet  int
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (m *MemcachedRequesterFactory) GetRequester(uint64) bench.Requester {
+	keys := m.Keys
+	if keys == nil {
+		keys = Sequential(0)
+	}
+	multiGet := m.MultiGet
+	if multiGet < 1 {
+		multiGet = 1
+	}
+	return &memcachedRequester{
+		url:       m.URL,
+		binary:    m.Binary,
+		keys:      keys,
+		keyPrefix: m.KeyPrefix,
+		readRatio: m.ReadRatio,
+		valueSize: m.ValueSize,
+		multiGet:  multiGet,
+	}
+}
+
+// memcachedRequester implements Requester by sending a get or set command to
+// Memcached and waiting for the reply.
+type memcachedRequester struct {
+	url       string
+	binary    bool
+	keys      Generator
+	keyPrefix string
+	readRatio float64
+	valueSize int
+	multiGet  int
+	conn      net.Conn
+	rw        *bufio.ReadWriter
+	value     []byte
+	header    []byte
+}
+
+// Setup prepares the Requester for benchmarking.
+func (m *memcachedRequester) Setup() error {
+	conn, err := net.Dial("tcp", m.url)
+	if err != nil {
+		return err
+	}
+	m.conn = conn
+	m.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	m.value = newPayload(nil, m.valueSize)
+	m.header = make([]byte, memcachedHeaderSize)
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (m *memcachedRequester) Request() error {
+	if err := m.conn.SetDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		return err
+	}
+	var err error
+	if rand.Float64() < m.readRatio {
+		keys := make([]string, m.multiGet)
+		for i := range keys {
+			keys[i] = m.nextKey()
+		}
+		if m.binary {
+			err = m.binaryGet(keys)
+		} else {
+			err = m.textGet(keys)
+		}
+	} else {
+		if m.binary {
+			err = m.binarySet(m.nextKey())
+		} else {
+			err = m.textSet(m.nextKey())
+		}
+	}
+	return timeoutError(err)
+}
+
+// nextKey returns the next generated key.
+func (m *memcachedRequester) nextKey() string {
+	return fmt.Sprint(m.keyPrefix, m.keys())
+}
+
+// textGet fetches the given keys using the text protocol.
+func (m *memcachedRequester) textGet(keys []string) error {
+	m.rw.WriteString("get")
+	for _, key := range keys {
+		m.rw.WriteString(" ")
+		m.rw.WriteString(key)
+	}
+	m.rw.WriteString("\r\n")
+	if err := m.rw.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := m.rw.ReadSlice('\n')
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(line, []byte("END\r\n")) {
+			return nil
+		}
+		// VALUE <key> <flags> <bytes> [<cas unique>]\r\n
+		fields := bytes.Fields(line)
+		if len(fields) < 4 || !bytes.Equal(fields[0], []byte("VALUE")) {
+			return memcachedTextError(line)
+		}
+		size, err := strconv.Atoi(string(fields[3]))
+		if err != nil {
+			return err
+		}
+		if _, err := m.rw.Discard(size + 2); err != nil {
+			return err
+		}
+	}
+}
+
+// textSet stores the value under key using the text protocol.
+func (m *memcachedRequester) textSet(key string) error {
+	fmt.Fprintf(m.rw, "set %s 0 0 %d\r\n", key, len(m.value))
+	m.rw.Write(m.value)
+	m.rw.WriteString("\r\n")
+	if err := m.rw.Flush(); err != nil {
+		return err
+	}
+	line, err := m.rw.ReadSlice('\n')
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(line, []byte("STORED\r\n")) {
+		return memcachedTextError(line)
+	}
+	return nil
+}
+
+// memcachedTextError returns an error for an unexpected text protocol reply.
+func memcachedTextError(line []byte) error {
+	return errors.New("requester: memcached: " + string(bytes.TrimSpace(line)))
+}
+
+// binaryGet fetches the given keys using the binary protocol. Multiple keys
+// are fetched with quiet gets terminated by a no-op.
+func (m *memcachedRequester) binaryGet(keys []string) error {
+	if len(keys) == 1 {
+		m.writeBinary(memcachedOpGet, keys[0], nil, nil)
+	} else {
+		for _, key := range keys {
+			m.writeBinary(memcachedOpGetKQ, key, nil, nil)
+		}
+		m.writeBinary(memcachedOpNoop, "", nil, nil)
+	}
+	if err := m.rw.Flush(); err != nil {
+		return err
+	}
+	// Read every response so the connection stays in sync, but report the
+	// first error.
+	var firstErr error
+	for {
+		opcode, err := m.readBinary()
+		if _, ok := err.(memcachedStatusError); ok {
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else if err != nil {
+			return err
+		}
+		if len(keys) == 1 || opcode == memcachedOpNoop {
+			return firstErr
+		}
+	}
+}
+
+// binarySet stores the value under key using the binary protocol.
+func (m *memcachedRequester) binarySet(key string) error {
+	// Extras are 4 bytes of flags and 4 bytes of expiration, both zero.
+	m.writeBinary(memcachedOpSet, key, make([]byte, 8), m.value)
+	if err := m.rw.Flush(); err != nil {
+		return err
+	}
+	_, err := m.readBinary()
+	return err
+}
+
+// writeBinary buffers a binary protocol request.
+func (m *memcachedRequester) writeBinary(opcode byte, key string, extras, value []byte) {
+	header := m.header
+	for i := range header {
+		header[i] = 0
+	}
+	header[0] = memcachedRequestMagic
+	header[1] = opcode
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(key)))
+	header[4] = byte(len(extras))
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(extras)+len(key)+len(value)))
+	m.rw.Write(header)
+	m.rw.Write(extras)
+	m.rw.WriteString(key)
+	m.rw.Write(value)
+}
+
+// memcachedStatusError is returned for a binary protocol response with an
+// error status.
+type memcachedStatusError string
+
+func (e memcachedStatusError) Error() string { return string(e) }
+
+// readBinary reads a binary protocol response and returns its opcode. A
+// missing key is not treated as an error.
+func (m *memcachedRequester) readBinary() (byte, error) {
+	if _, err := io.ReadFull(m.rw, m.header); err != nil {
+		return 0, err
+	}
+	if m.header[0] != memcachedResponseMagic {
+		return 0, errors.New("requester: memcached: invalid response magic")
+	}
+	var (
+		opcode    = m.header[1]
+		keySize   = int(binary.BigEndian.Uint16(m.header[2:4]))
+		extraSize = int(m.header[4])
+		status    = binary.BigEndian.Uint16(m.header[6:8])
+		bodySize  = binary.BigEndian.Uint32(m.header[8:12])
+	)
+	if status != memcachedStatusOK && status != memcachedStatusKeyNotFound {
+		body := make([]byte, bodySize)
+		if _, err := io.ReadFull(m.rw, body); err != nil {
+			return 0, err
+		}
+		// The error message follows any extras and key.
+		var msg []byte
+		if extraSize+keySize <= len(body) {
+			msg = body[extraSize+keySize:]
+		}
+		return opcode, memcachedStatusError(fmt.Sprintf("requester: memcached: status %d: %s", status, msg))
+	}
+	_, err := m.rw.Discard(int(bodySize))
+	return opcode, err
+}
+
+// Teardown is called upon benchmark completion.
+func (m *memcachedRequester) Teardown() error {
+	if err := m.conn.Close(); err != nil {
+		return err
+	}
+	m.conn = nil
+	m.rw = nil
+	return nil
+}
@@ -0,0 +1,355 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeMemcached is a minimal Memcached server for testing, speaking the text
+// or binary protocol depending on the first byte of each connection. Sets of
+// keys starting with "fail" are answered with a server error.
+type fakeMemcached struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	items    map[string][]byte
+	commands []string
+}
+
+// newFakeMemcached starts a fakeMemcached listening on a local port.
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeMemcached{listener: l, items: make(map[string][]byte)}
+	go f.serve()
+	return f
+}
+
+func (f *fakeMemcached) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeMemcached) close() {
+	f.listener.Close()
+}
+
+func (f *fakeMemcached) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.serveConn(conn)
+	}
+}
+
+func (f *fakeMemcached) serveConn(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	first, err := rw.Peek(1)
+	if err != nil {
+		return
+	}
+	if first[0] == memcachedRequestMagic {
+		f.serveBinary(rw)
+	} else {
+		f.serveText(rw)
+	}
+}
+
+// record notes a received command.
+func (f *fakeMemcached) record(cmd string) {
+	f.mu.Lock()
+	f.commands = append(f.commands, cmd)
+	f.mu.Unlock()
+}
+
+// received returns the commands received so far and forgets them.
+func (f *fakeMemcached) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	commands := f.commands
+	f.commands = nil
+	return commands
+}
+
+func (f *fakeMemcached) get(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.items[key]
+	return value, ok
+}
+
+// set stores value under key, reporting false for keys which fail.
+func (f *fakeMemcached) set(key string, value []byte) bool {
+	if strings.HasPrefix(key, "fail") {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[key] = value
+	return true
+}
+
+func (f *fakeMemcached) serveText(rw *bufio.ReadWriter) error {
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		f.record(strings.Join(fields, " "))
+		switch fields[0] {
+		case "get":
+			for _, key := range fields[1:] {
+				if value, ok := f.get(key); ok {
+					fmt.Fprintf(rw, "VALUE %s 0 %d\r\n%s\r\n", key, len(value), value)
+				}
+			}
+			rw.WriteString("END\r\n")
+		case "set":
+			size, err := strconv.Atoi(fields[4])
+			if err != nil {
+				return err
+			}
+			value := make([]byte, size+2)
+			if _, err := io.ReadFull(rw, value); err != nil {
+				return err
+			}
+			if f.set(fields[1], value[:size]) {
+				rw.WriteString("STORED\r\n")
+			} else {
+				rw.WriteString("SERVER_ERROR out of memory\r\n")
+			}
+		default:
+			rw.WriteString("ERROR\r\n")
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *fakeMemcached) serveBinary(rw *bufio.ReadWriter) error {
+	header := make([]byte, memcachedHeaderSize)
+	for {
+		if _, err := io.ReadFull(rw, header); err != nil {
+			return err
+		}
+		var (
+			opcode    = header[1]
+			keySize   = int(binary.BigEndian.Uint16(header[2:4]))
+			extraSize = int(header[4])
+			bodySize  = int(binary.BigEndian.Uint32(header[8:12]))
+		)
+		body := make([]byte, bodySize)
+		if _, err := io.ReadFull(rw, body); err != nil {
+			return err
+		}
+		key := string(body[extraSize : extraSize+keySize])
+		value := body[extraSize+keySize:]
+
+		switch opcode {
+		case memcachedOpGet, memcachedOpGetKQ:
+			name := "GET"
+			if opcode == memcachedOpGetKQ {
+				name = "GETKQ"
+			}
+			f.record(name + " " + key)
+			stored, ok := f.get(key)
+			switch {
+			case ok && opcode == memcachedOpGetKQ:
+				writeMemcachedBinary(rw, opcode, memcachedStatusOK, key, stored)
+			case ok:
+				writeMemcachedBinary(rw, opcode, memcachedStatusOK, "", stored)
+			case opcode == memcachedOpGet:
+				writeMemcachedBinary(rw, opcode, memcachedStatusKeyNotFound, "", []byte("Not found"))
+			}
+		case memcachedOpSet:
+			f.record("SET " + key)
+			if f.set(key, value) {
+				writeMemcachedBinary(rw, opcode, memcachedStatusOK, "", nil)
+			} else {
+				writeMemcachedBinary(rw, opcode, 0x82, "", []byte("Out of memory"))
+			}
+		case memcachedOpNoop:
+			f.record("NOOP")
+			writeMemcachedBinary(rw, opcode, memcachedStatusOK, "", nil)
+		}
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// writeMemcachedBinary writes a binary protocol response, with flags as
+// extras for successful gets.
+func writeMemcachedBinary(w io.Writer, opcode byte, status uint16, key string, value []byte) {
+	var extras []byte
+	if status == memcachedStatusOK && (opcode == memcachedOpGet || opcode == memcachedOpGetKQ) {
+		extras = make([]byte, 4)
+	}
+	header := make([]byte, memcachedHeaderSize)
+	header[0] = memcachedResponseMagic
+	header[1] = opcode
+	binary.BigEndian.PutUint16(header[2:4], uint16(len(key)))
+	header[4] = byte(len(extras))
+	binary.BigEndian.PutUint16(header[6:8], status)
+	binary.BigEndian.PutUint32(header[8:12], uint32(len(extras)+len(key)+len(value)))
+	w.Write(header)
+	w.Write(extras)
+	io.WriteString(w, key)
+	w.Write(value)
+}
+
+// keySequence returns a Generator which produces the given keys in turn.
+func keySequence(k ...string) Generator {
+	var (
+		mu   sync.Mutex
+		next int
+	)
+	return func() interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		key := k[next%len(k)]
+		next++
+		return key
+	}
+}
+
+func TestMemcachedRequester(t *testing.T) {
+	for _, bin := range []bool{false, true} {
+		t.Run(fmt.Sprintf("binary=%v", bin), func(t *testing.T) {
+			testMemcachedRequester(t, bin)
+		})
+	}
+}
+
+func testMemcachedRequester(t *testing.T, bin bool) {
+	server := newFakeMemcached(t)
+	defer server.close()
+
+	request := func(factory *MemcachedRequesterFactory, n int) error {
+		factory.URL = server.addr()
+		factory.Binary = bin
+		r := factory.GetRequester(0)
+		if err := r.Setup(); err != nil {
+			t.Fatal(err)
+		}
+		defer r.Teardown()
+		var firstErr error
+		for i := 0; i < n; i++ {
+			if err := r.Request(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	expect := func(commands ...string) {
+		t.Helper()
+		if got := server.received(); strings.Join(got, "|") != strings.Join(commands, "|") {
+			t.Fatalf("expected commands %q, got %q", commands, got)
+		}
+	}
+
+	// Sets.
+	err := request(&MemcachedRequesterFactory{
+		Keys:      Sequential(0),
+		KeyPrefix: "key",
+		ValueSize: 10,
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin {
+		expect("SET key0", "SET key1")
+	} else {
+		expect("set key0 0 0 10", "set key1 0 0 10")
+	}
+	if value, ok := server.get("key1"); !ok || len(value) != 10 {
+		t.Fatalf("expected 10-byte value to be stored, got %q", value)
+	}
+
+	// Single gets of a hit and a miss. Misses aren't errors.
+	err = request(&MemcachedRequesterFactory{
+		Keys:      keySequence("key0", "missing"),
+		ReadRatio: 1,
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin {
+		expect("GET key0", "GET missing")
+	} else {
+		expect("get key0", "get missing")
+	}
+
+	// Multi-gets of hits and misses, repeated to check that every response
+	// is consumed.
+	err = request(&MemcachedRequesterFactory{
+		Keys:      keySequence("key0", "missing", "key1"),
+		ReadRatio: 1,
+		MultiGet:  3,
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin {
+		expect("GETKQ key0", "GETKQ missing", "GETKQ key1", "NOOP",
+			"GETKQ key0", "GETKQ missing", "GETKQ key1", "NOOP")
+	} else {
+		expect("get key0 missing key1", "get key0 missing key1")
+	}
+
+	// Server errors are reported without losing sync with the connection.
+	err = request(&MemcachedRequesterFactory{
+		Keys: keySequence("fail", "key2"),
+	}, 2)
+	if err == nil || !strings.Contains(err.Error(), "memcached") {
+		t.Fatalf("expected memcached error, got %v", err)
+	}
+	if _, ok := server.get("key2"); !ok {
+		t.Fatal("expected set after error to succeed")
+	}
+}
+
+func TestMemcachedBinaryGetError(t *testing.T) {
+	var buf bytes.Buffer
+	writeMemcachedBinary(&buf, memcachedOpGetKQ, 0x84, "a", []byte("Out of memory"))
+	writeMemcachedBinary(&buf, memcachedOpGetKQ, memcachedStatusOK, "b", []byte("value"))
+	writeMemcachedBinary(&buf, memcachedOpNoop, memcachedStatusOK, "", nil)
+	buf.WriteString("rest")
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		// Drain the request before replying.
+		io.ReadFull(server, make([]byte, 3*memcachedHeaderSize+2))
+		server.Write(buf.Bytes())
+	}()
+	m := &memcachedRequester{
+		conn:   client,
+		rw:     bufio.NewReadWriter(bufio.NewReader(client), bufio.NewWriter(client)),
+		header: make([]byte, memcachedHeaderSize),
+	}
+	err := m.binaryGet([]string{"a", "b"})
+	if err == nil || !strings.Contains(err.Error(), "status 132: Out of memory") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	// The remaining responses were consumed up to the no-op.
+	rest, err := m.rw.Peek(4)
+	if err != nil || string(rest) != "rest" {
+		t.Fatalf("expected responses to be consumed, got %q, %v", rest, err)
+	}
+}
@@ -0,0 +1,179 @@
+package requester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tylertreat/bench"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// MongoDBOperation is an operation performed by the MongoDB Requester.
+type MongoDBOperation string
+
+// MongoDB operations.
+const (
+	// MongoDBFind finds the documents matching Filter and drains the cursor.
+	MongoDBFind MongoDBOperation = "find"
+
+	// MongoDBInsert inserts Document.
+	MongoDBInsert MongoDBOperation = "insert"
+
+	// MongoDBUpdate applies the update Document to the first document
+	// matching Filter.
+	MongoDBUpdate MongoDBOperation = "update"
+
+	// MongoDBAggregate runs the aggregation Pipeline and drains the cursor.
+	MongoDBAggregate MongoDBOperation = "aggregate"
+)
+
+// MongoDBRequesterFactory implements RequesterFactory by creating a Requester
+// which performs an operation on a MongoDB collection. Filter, Document and
+// Pipeline generate the filter, document and aggregation pipeline for each
+// request, typically using DocumentTemplate, and Filter defaults to matching
+// every document. WriteConcern applies to inserts and updates and defaults to
+// the server's default.
+type MongoDBRequesterFactory struct {
+	URL          string
+	Database     string
+	Collection   string
+	Operation    MongoDBOperation
+	Filter       Generator
+	Document     Generator
+	Pipeline     Generator
+	WriteConcern *writeconcern.WriteConcern
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (m *MongoDBRequesterFactory) GetRequester(uint64) bench.Requester {
+	filter := m.Filter
+	if filter == nil {
+		filter = Constant(bson.D{})
+	}
+	return &mongoDBRequester{
+		url:          m.URL,
+		database:     m.Database,
+		collection:   m.Collection,
+		operation:    m.Operation,
+		filter:       filter,
+		document:     m.Document,
+		pipeline:     m.Pipeline,
+		writeConcern: m.WriteConcern,
+	}
+}
+
+// DocumentTemplate returns a Generator which produces copies of template
+// with the given fields set to a freshly generated value, for example a
+// random key or payload.
+func DocumentTemplate(template bson.M, fields map[string]Generator) Generator {
+	return func() interface{} {
+		doc := make(bson.M, len(template)+len(fields))
+		for k, v := range template {
+			doc[k] = v
+		}
+		for k, g := range fields {
+			doc[k] = g()
+		}
+		return doc
+	}
+}
+
+// mongoDBRequester implements Requester by performing an operation on a
+// MongoDB collection.
+type mongoDBRequester struct {
+	url          string
+	database     string
+	collection   string
+	operation    MongoDBOperation
+	filter       Generator
+	document     Generator
+	pipeline     Generator
+	writeConcern *writeconcern.WriteConcern
+	client       *mongo.Client
+	coll         *mongo.Collection
+}
+
+// Setup prepares the Requester for benchmarking.
+func (m *mongoDBRequester) Setup() error {
+	switch m.operation {
+	case MongoDBFind:
+	case MongoDBInsert, MongoDBUpdate:
+		if m.document == nil {
+			return fmt.Errorf("requester: MongoDB %s requires a Document generator", m.operation)
+		}
+	case MongoDBAggregate:
+		if m.pipeline == nil {
+			return errors.New("requester: MongoDB aggregate requires a Pipeline generator")
+		}
+	default:
+		return fmt.Errorf("requester: unknown MongoDB operation %q", m.operation)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(m.url))
+	if err != nil {
+		return err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(ctx)
+		return err
+	}
+	opts := options.Collection()
+	if m.writeConcern != nil {
+		opts.SetWriteConcern(m.writeConcern)
+	}
+	m.client = client
+	m.coll = client.Database(m.database).Collection(m.collection, opts)
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (m *mongoDBRequester) Request() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var (
+		cursor *mongo.Cursor
+		err    error
+	)
+	switch m.operation {
+	case MongoDBInsert:
+		_, err = m.coll.InsertOne(ctx, m.document())
+		return err
+	case MongoDBUpdate:
+		_, err = m.coll.UpdateOne(ctx, m.filter(), m.document())
+		return err
+	case MongoDBFind:
+		cursor, err = m.coll.Find(ctx, m.filter())
+	case MongoDBAggregate:
+		cursor, err = m.coll.Aggregate(ctx, m.pipeline())
+	}
+	if err != nil {
+		return err
+	}
+	for cursor.Next(ctx) {
+	}
+	if err := cursor.Err(); err != nil {
+		cursor.Close(ctx)
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// Teardown is called upon benchmark completion.
+func (m *mongoDBRequester) Teardown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := m.client.Disconnect(ctx); err != nil {
+		return err
+	}
+	m.client = nil
+	m.coll = nil
+	return nil
+}
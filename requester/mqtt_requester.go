@@ -0,0 +1,330 @@
+package requester
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/tylertreat/bench"
+)
+
+// mqttBuffer is the number of received messages buffered by the MQTT
+// Requester. Messages arriving while the buffer is full are dropped rather
+// than blocking the client.
+const mqttBuffer = 16
+
+// MQTTRequesterFactory implements RequesterFactory by creating a Requester
+// which publishes messages to an MQTT broker and waits to receive them.
+//
+// URL is the broker address, e.g. "tcp://localhost:1883". QoS sets the
+// quality of service (0, 1 or 2) used to publish and subscribe. If Retained
+// is set, messages are published as retained messages. CleanSession sets the
+// clean session flag on connect, or clean start for MQTT 5. ProtocolVersion
+// selects MQTT 3.1 (3), 3.1.1 (4) or 5 (5), defaulting to 3.1.1 with fallback
+// to 3.1. Each message carries a 16-byte sequence number ahead of the payload
+// so that late messages from earlier requests which timed out are skipped.
+//
+// The remaining options only apply to MQTT 5. SessionExpiry sets how long the
+// broker keeps the session after disconnecting, MessageExpiry sets the
+// lifetime of each published message, and UserProperties are sent with each
+// published message.
+type MQTTRequesterFactory struct {
+	URL             string
+	PayloadSize     int
+	Topic           string
+	ClientID        string
+	QoS             byte
+	Retained        bool
+	CleanSession    bool
+	ProtocolVersion uint
+	SessionExpiry   time.Duration
+	MessageExpiry   time.Duration
+	UserProperties  map[string]string
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (m *MQTTRequesterFactory) GetRequester(num uint64) bench.Requester {
+	var (
+		topic    = m.Topic + "-" + strconv.FormatUint(num, 10)
+		clientID = m.ClientID + "-" + strconv.FormatUint(num, 10)
+	)
+	if m.ProtocolVersion == 5 {
+		return &mqtt5Requester{
+			url:            m.URL,
+			payloadSize:    m.PayloadSize,
+			topic:          topic,
+			clientID:       clientID,
+			qos:            m.QoS,
+			retained:       m.Retained,
+			cleanStart:     m.CleanSession,
+			sessionExpiry:  m.SessionExpiry,
+			messageExpiry:  m.MessageExpiry,
+			userProperties: m.UserProperties,
+		}
+	}
+	return &mqttRequester{
+		url:             m.URL,
+		payloadSize:     m.PayloadSize,
+		topic:           topic,
+		clientID:        clientID,
+		qos:             m.QoS,
+		retained:        m.Retained,
+		cleanSession:    m.CleanSession,
+		protocolVersion: m.ProtocolVersion,
+	}
+}
+
+// mqttRequester implements Requester by publishing a message to an MQTT 3.1
+// or 3.1.1 broker and waiting to receive it.
+type mqttRequester struct {
+	url             string
+	payloadSize     int
+	topic           string
+	clientID        string
+	qos             byte
+	retained        bool
+	cleanSession    bool
+	protocolVersion uint
+	client          mqtt.Client
+	msg             []byte
+	msgChan         chan []byte
+	seq             uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (m *mqttRequester) Setup() error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(m.url).
+		SetClientID(m.clientID).
+		SetCleanSession(m.cleanSession).
+		SetProtocolVersion(m.protocolVersion)
+	client := mqtt.NewClient(opts)
+	if err := mqttWait(client.Connect()); err != nil {
+		return err
+	}
+
+	m.msgChan = make(chan []byte, mqttBuffer)
+	handler := func(_ mqtt.Client, msg mqtt.Message) {
+		// Retained messages left by an earlier run are delivered on
+		// subscribe and must be ignored.
+		if msg.Retained() {
+			return
+		}
+		mqttDeliver(m.msgChan, msg.Payload())
+	}
+	if err := mqttWait(client.Subscribe(m.topic, m.qos, handler)); err != nil {
+		client.Disconnect(0)
+		return err
+	}
+	m.client = client
+	m.msg = newMQTTPayload(m.payloadSize)
+	m.seq = 0
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (m *mqttRequester) Request() error {
+	m.seq++
+	id := setMQTTSequence(m.msg, m.seq)
+	if err := mqttWait(m.client.Publish(m.topic, m.qos, m.retained, m.msg)); err != nil {
+		return err
+	}
+	return mqttAwait(m.msgChan, id)
+}
+
+// Teardown is called upon benchmark completion.
+func (m *mqttRequester) Teardown() error {
+	if err := mqttWait(m.client.Unsubscribe(m.topic)); err != nil {
+		return err
+	}
+	m.client.Disconnect(250)
+	m.client = nil
+	return nil
+}
+
+// mqtt5Requester implements Requester by publishing a message to an MQTT 5
+// broker and waiting to receive it.
+type mqtt5Requester struct {
+	url            string
+	payloadSize    int
+	topic          string
+	clientID       string
+	qos            byte
+	retained       bool
+	cleanStart     bool
+	sessionExpiry  time.Duration
+	messageExpiry  time.Duration
+	userProperties map[string]string
+	client         *paho.Client
+	publish        *paho.Publish
+	msgChan        chan []byte
+	seq            uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (m *mqtt5Requester) Setup() error {
+	conn, err := dialMQTT(m.url)
+	if err != nil {
+		return err
+	}
+	m.msgChan = make(chan []byte, mqttBuffer)
+	client := paho.NewClient(paho.ClientConfig{
+		Conn: conn,
+		OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+			func(pr paho.PublishReceived) (bool, error) {
+				// Retained messages left by an earlier run are delivered
+				// on subscribe and must be ignored.
+				if !pr.Packet.Retain {
+					mqttDeliver(m.msgChan, pr.Packet.Payload)
+				}
+				return true, nil
+			},
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	connect := &paho.Connect{
+		ClientID:   m.clientID,
+		KeepAlive:  30,
+		CleanStart: m.cleanStart,
+	}
+	if m.sessionExpiry > 0 {
+		expiry := uint32(m.sessionExpiry / time.Second)
+		connect.Properties = &paho.ConnectProperties{SessionExpiryInterval: &expiry}
+	}
+	connack, err := client.Connect(ctx, connect)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if connack.ReasonCode != 0 {
+		conn.Close()
+		return fmt.Errorf("requester: MQTT connect failed with reason code %d", connack.ReasonCode)
+	}
+	_, err = client.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: m.topic, QoS: m.qos}},
+	})
+	if err != nil {
+		client.Disconnect(&paho.Disconnect{})
+		return err
+	}
+
+	props := &paho.PublishProperties{}
+	if m.messageExpiry > 0 {
+		expiry := uint32(m.messageExpiry / time.Second)
+		props.MessageExpiry = &expiry
+	}
+	for key, value := range m.userProperties {
+		props.User.Add(key, value)
+	}
+	m.client = client
+	m.publish = &paho.Publish{
+		Topic:      m.topic,
+		QoS:        m.qos,
+		Retain:     m.retained,
+		Properties: props,
+		Payload:    newMQTTPayload(m.payloadSize),
+	}
+	m.seq = 0
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (m *mqtt5Requester) Request() error {
+	m.seq++
+	id := setMQTTSequence(m.publish.Payload, m.seq)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := m.client.Publish(ctx, m.publish); err != nil {
+		return err
+	}
+	return mqttAwait(m.msgChan, id)
+}
+
+// Teardown is called upon benchmark completion.
+func (m *mqtt5Requester) Teardown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := m.client.Unsubscribe(ctx, &paho.Unsubscribe{Topics: []string{m.topic}}); err != nil {
+		return err
+	}
+	if err := m.client.Disconnect(&paho.Disconnect{}); err != nil {
+		return err
+	}
+	m.client = nil
+	return nil
+}
+
+// dialMQTT connects to the MQTT broker at rawurl, using TLS for the "ssl",
+// "tls" and "mqtts" schemes.
+func dialMQTT(rawurl string) (net.Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		return net.DialTimeout("tcp", u.Host, 30*time.Second)
+	case "ssl", "tls", "mqtts":
+		dialer := &net.Dialer{Timeout: 30 * time.Second}
+		return tls.DialWithDialer(dialer, "tcp", u.Host, nil)
+	default:
+		return nil, fmt.Errorf("requester: unsupported MQTT URL scheme %q", u.Scheme)
+	}
+}
+
+// newMQTTPayload returns a random payload of the given size preceded by room
+// for the sequence number.
+func newMQTTPayload(size int) []byte {
+	return append(make([]byte, correlationIDSize), newPayload(nil, size)...)
+}
+
+// setMQTTSequence writes the sequence number to the start of msg and returns
+// it.
+func setMQTTSequence(msg []byte, seq uint64) []byte {
+	id := []byte(fmt.Sprintf("%016x", seq))
+	copy(msg, id)
+	return id
+}
+
+// mqttDeliver passes a received payload to the waiting Request without
+// blocking the client.
+func mqttDeliver(msgChan chan []byte, payload []byte) {
+	select {
+	case msgChan <- payload:
+	default:
+	}
+}
+
+// mqttAwait waits for the message carrying the given sequence number,
+// skipping messages from earlier requests.
+func mqttAwait(msgChan chan []byte, id []byte) error {
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case payload := <-msgChan:
+			if bytes.HasPrefix(payload, id) {
+				return nil
+			}
+		case <-timeout:
+			return errors.New("requester: Request timed out receiving")
+		}
+	}
+}
+
+// mqttWait waits for an MQTT operation to complete and returns its error.
+func mqttWait(token mqtt.Token) error {
+	if !token.WaitTimeout(30 * time.Second) {
+		return errors.New("requester: MQTT operation timed out")
+	}
+	return token.Error()
+}
@@ -0,0 +1,158 @@
+package requester
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tylertreat/bench"
+)
+
+// NATSJetStreamRequesterFactory implements RequesterFactory by creating a
+// Requester which publishes messages to a NATS JetStream stream and waits to
+// consume them. Each connection creates its own stream and durable consumer
+// in Setup and deletes the stream in Teardown. Storage and Replicas configure
+// the stream. Messages are published synchronously, waiting for the stream's
+// acknowledgement. If Pull is set, messages are fetched by a pull consumer,
+// otherwise they're delivered to a push consumer. AckPolicy sets the
+// consumer's ack policy, and messages are acknowledged on receipt unless it
+// is nats.AckNonePolicy. Since pull consumers require acknowledgements,
+// AckPolicy defaults to nats.AckExplicitPolicy for them.
+type NATSJetStreamRequesterFactory struct {
+	URL         string
+	PayloadSize int
+	Stream      string
+	Subject     string
+	Storage     nats.StorageType
+	Replicas    int
+	Pull        bool
+	AckPolicy   nats.AckPolicy
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (n *NATSJetStreamRequesterFactory) GetRequester(num uint64) bench.Requester {
+	ackPolicy := n.AckPolicy
+	if n.Pull && ackPolicy == nats.AckNonePolicy {
+		ackPolicy = nats.AckExplicitPolicy
+	}
+	return &natsJetStreamRequester{
+		url:         n.URL,
+		payloadSize: n.PayloadSize,
+		stream:      n.Stream + "-" + strconv.FormatUint(num, 10),
+		subject:     n.Subject + "-" + strconv.FormatUint(num, 10),
+		durable:     "bench-" + strconv.FormatUint(num, 10),
+		storage:     n.Storage,
+		replicas:    n.Replicas,
+		pull:        n.Pull,
+		ackPolicy:   ackPolicy,
+	}
+}
+
+// natsJetStreamRequester implements Requester by publishing a message to a
+// NATS JetStream stream and waiting to consume it.
+type natsJetStreamRequester struct {
+	url         string
+	payloadSize int
+	stream      string
+	subject     string
+	durable     string
+	storage     nats.StorageType
+	replicas    int
+	pull        bool
+	ackPolicy   nats.AckPolicy
+	conn        *nats.Conn
+	js          nats.JetStreamContext
+	sub         *nats.Subscription
+	msg         []byte
+}
+
+// Setup prepares the Requester for benchmarking.
+func (n *natsJetStreamRequester) Setup() error {
+	conn, err := nats.Connect(n.url)
+	if err != nil {
+		return err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     n.stream,
+		Subjects: []string{n.subject},
+		Storage:  n.storage,
+		Replicas: n.replicas,
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	opts := []nats.SubOpt{nats.BindStream(n.stream), nats.DeliverNew()}
+	switch n.ackPolicy {
+	case nats.AckAllPolicy:
+		opts = append(opts, nats.AckAll())
+	case nats.AckExplicitPolicy:
+		opts = append(opts, nats.AckExplicit())
+	default:
+		opts = append(opts, nats.AckNone())
+	}
+	var sub *nats.Subscription
+	if n.pull {
+		sub, err = js.PullSubscribe(n.subject, n.durable, opts...)
+	} else {
+		sub, err = js.SubscribeSync(n.subject, append(opts, nats.Durable(n.durable))...)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	n.conn = conn
+	n.js = js
+	n.sub = sub
+	n.msg = newPayload(nil, n.payloadSize)
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (n *natsJetStreamRequester) Request() error {
+	if _, err := n.js.Publish(n.subject, n.msg); err != nil {
+		return err
+	}
+
+	var msg *nats.Msg
+	if n.pull {
+		msgs, err := n.sub.Fetch(1, nats.MaxWait(30*time.Second))
+		if err != nil {
+			return err
+		}
+		msg = msgs[0]
+	} else {
+		var err error
+		if msg, err = n.sub.NextMsg(30 * time.Second); err != nil {
+			return err
+		}
+	}
+
+	if n.ackPolicy == nats.AckNonePolicy {
+		return nil
+	}
+	return msg.Ack()
+}
+
+// Teardown is called upon benchmark completion.
+func (n *natsJetStreamRequester) Teardown() error {
+	if err := n.sub.Unsubscribe(); err != nil {
+		return err
+	}
+	// Deleting the stream discards its messages and the durable consumer.
+	if err := n.js.DeleteStream(n.stream); err != nil {
+		return err
+	}
+	n.sub = nil
+	n.js = nil
+	n.conn.Close()
+	n.conn = nil
+	return nil
+}
@@ -0,0 +1,122 @@
+package requester
+
+import (
+	"time"
+
+	"github.com/nats-io/go-nats"
+	"github.com/tylertreat/bench"
+)
+
+// NATSRequestRequesterFactory implements RequesterFactory by creating a
+// Requester which sends requests to a service over NATS and waits for the
+// reply. Every connection sends requests on the same Subject. If Responder
+// is set, each connection also runs a responder which echoes requests back,
+// subscribed in QueueGroup, which defaults to "bench", so that requests are
+// load balanced across the responders. Otherwise an external service is
+// expected to reply.
+type NATSRequestRequesterFactory struct {
+	URL         string
+	PayloadSize int
+	Subject     string
+	QueueGroup  string
+	Responder   bool
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (n *NATSRequestRequesterFactory) GetRequester(uint64) bench.Requester {
+	queueGroup := n.QueueGroup
+	if queueGroup == "" {
+		queueGroup = "bench"
+	}
+	return &natsRequestRequester{
+		url:         n.URL,
+		payloadSize: n.PayloadSize,
+		subject:     n.Subject,
+		queueGroup:  queueGroup,
+		responder:   n.Responder,
+	}
+}
+
+// natsRequestRequester implements Requester by sending a request over NATS
+// and waiting for the reply.
+type natsRequestRequester struct {
+	url           string
+	payloadSize   int
+	subject       string
+	queueGroup    string
+	responder     bool
+	conn          *nats.Conn
+	responderConn *nats.Conn
+	responderSub  *nats.Subscription
+	msg           []byte
+}
+
+// Setup prepares the Requester for benchmarking.
+func (n *natsRequestRequester) Setup() error {
+	if n.responder {
+		if err := n.startResponder(); err != nil {
+			return err
+		}
+	}
+	conn, err := nats.Connect(n.url)
+	if err != nil {
+		n.stopResponder()
+		return err
+	}
+	n.conn = conn
+	n.msg = make([]byte, n.payloadSize)
+	return nil
+}
+
+// startResponder subscribes a responder which echoes requests back to the
+// requester on its own connection.
+func (n *natsRequestRequester) startResponder() error {
+	conn, err := nats.Connect(n.url)
+	if err != nil {
+		return err
+	}
+	sub, err := conn.QueueSubscribe(n.subject, n.queueGroup, func(msg *nats.Msg) {
+		conn.Publish(msg.Reply, msg.Data)
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	// Ensure the subscription is registered with the server before any
+	// requests are sent.
+	if err := conn.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+	n.responderConn = conn
+	n.responderSub = sub
+	return nil
+}
+
+// stopResponder unsubscribes the responder, if any, and closes its
+// connection.
+func (n *natsRequestRequester) stopResponder() error {
+	if n.responderSub == nil {
+		return nil
+	}
+	if err := n.responderSub.Unsubscribe(); err != nil {
+		return err
+	}
+	n.responderSub = nil
+	n.responderConn.Close()
+	n.responderConn = nil
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (n *natsRequestRequester) Request() error {
+	_, err := n.conn.Request(n.subject, n.msg, 30*time.Second)
+	return err
+}
+
+// Teardown is called upon benchmark completion.
+func (n *natsRequestRequester) Teardown() error {
+	n.conn.Close()
+	n.conn = nil
+	return n.stopResponder()
+}
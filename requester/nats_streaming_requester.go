@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/codahale/hdrhistogram"
 	"github.com/nats-io/go-nats-streaming"
 	"github.com/tylertreat/bench"
 )
@@ -14,46 +16,108 @@ import (
 // NATSStreamingRequesterFactory implements RequesterFactory by creating a
 // Requester which publishes messages to NATS Streaming and waits to receive
 // them.
+//
+// ClusterID defaults to "test-cluster". If DurableName is set, each
+// connection subscribes with a durable subscription of that name, using a
+// client ID which is stable across runs, and the subscription is closed
+// rather than unsubscribed in Teardown so that the server keeps its state. If
+// ManualAcks is set, received messages are acknowledged explicitly.
+// MaxInflight and AckWait configure the subscription and default to the
+// server's defaults. Each Request waits for the message to be delivered,
+// while the latency of each publish acknowledgement is recorded separately,
+// as it arrives, in PublishAckHistogram.
 type NATSStreamingRequesterFactory struct {
 	PayloadSize int
 	Subject     string
 	ClientID    string
 	URL         string
+	ClusterID   string
+	DurableName string
+	ManualAcks  bool
+	MaxInflight int
+	AckWait     time.Duration
+
+	mu         sync.Mutex
+	histograms []*latencyHistogram
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (n *NATSStreamingRequesterFactory) GetRequester(num uint64) bench.Requester {
+	clusterID := n.ClusterID
+	if clusterID == "" {
+		clusterID = "test-cluster"
+	}
+	var (
+		clientID    = n.ClientID + "-" + strconv.FormatUint(num, 10)
+		durableName string
+	)
+	if n.DurableName != "" {
+		durableName = n.DurableName + "-" + strconv.FormatUint(num, 10)
+	}
+	publishAcks := newLatencyHistogram()
+	n.mu.Lock()
+	n.histograms = append(n.histograms, publishAcks)
+	n.mu.Unlock()
 	return &natsStreamingRequester{
 		url:         n.URL,
-		clientID:    n.ClientID,
+		clusterID:   clusterID,
+		clientID:    clientID,
 		payloadSize: n.PayloadSize,
 		subject:     n.Subject + "-" + strconv.FormatUint(num, 10),
+		durableName: durableName,
+		manualAcks:  n.ManualAcks,
+		maxInflight: n.MaxInflight,
+		ackWait:     n.AckWait,
+		publishAcks: publishAcks,
 	}
 }
 
+// PublishAckHistogram returns the distribution of publish acknowledgement
+// latencies, in nanoseconds, across every connection.
+func (n *NATSStreamingRequesterFactory) PublishAckHistogram() *hdrhistogram.Histogram {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return mergeLatencyHistograms(n.histograms)
+}
+
 // natsStreamingRequester implements Requester by publishing a message to NATS
 // Streaming and waiting to receive it.
 type natsStreamingRequester struct {
 	url         string
+	clusterID   string
 	clientID    string
 	payloadSize int
 	subject     string
+	durableName string
+	manualAcks  bool
+	maxInflight int
+	ackWait     time.Duration
 	conn        stan.Conn
 	sub         stan.Subscription
 	msg         []byte
 	msgChan     chan []byte
+	publishAcks *latencyHistogram
 }
 
 // Setup prepares the Requester for benchmarking.
 func (n *natsStreamingRequester) Setup() error {
-	conn, err := stan.Connect("test-cluster", fmt.Sprintf("%s-%d", n.clientID, time.Now().UnixNano()), stan.NatsURL(n.url))
+	// Durable subscriptions are identified by client ID and durable name, so
+	// the client ID must be the same in each run to resume them.
+	clientID := n.clientID
+	if n.durableName == "" {
+		clientID = fmt.Sprintf("%s-%d", clientID, time.Now().UnixNano())
+	}
+	conn, err := stan.Connect(n.clusterID, clientID, stan.NatsURL(n.url))
 	if err != nil {
 		return err
 	}
 	n.msgChan = make(chan []byte)
 	sub, err := conn.Subscribe(n.subject, func(msg *stan.Msg) {
+		if n.manualAcks {
+			msg.Ack()
+		}
 		n.msgChan <- msg.Data
-	})
+	}, n.subscriptionOptions()...)
 	if err != nil {
 		conn.Close()
 		return err
@@ -67,9 +131,33 @@ func (n *natsStreamingRequester) Setup() error {
 	return nil
 }
 
+// subscriptionOptions returns the configured subscription options.
+func (n *natsStreamingRequester) subscriptionOptions() []stan.SubscriptionOption {
+	var opts []stan.SubscriptionOption
+	if n.durableName != "" {
+		opts = append(opts, stan.DurableName(n.durableName))
+	}
+	if n.manualAcks {
+		opts = append(opts, stan.SetManualAckMode())
+	}
+	if n.maxInflight > 0 {
+		opts = append(opts, stan.MaxInflight(n.maxInflight))
+	}
+	if n.ackWait > 0 {
+		opts = append(opts, stan.AckWait(n.ackWait))
+	}
+	return opts
+}
+
 // Request performs a synchronous request to the system under test.
 func (n *natsStreamingRequester) Request() error {
-	if _, err := n.conn.PublishAsync(n.subject, n.msg, nil); err != nil {
+	start := time.Now()
+	_, err := n.conn.PublishAsync(n.subject, n.msg, func(guid string, err error) {
+		if err == nil {
+			n.publishAcks.record(start)
+		}
+	})
+	if err != nil {
 		return err
 	}
 	select {
@@ -82,7 +170,13 @@ func (n *natsStreamingRequester) Request() error {
 
 // Teardown is called upon benchmark completion.
 func (n *natsStreamingRequester) Teardown() error {
-	if err := n.sub.Unsubscribe(); err != nil {
+	// Unsubscribing removes a durable subscription's state from the server,
+	// while closing it allows the next run to resume.
+	unsubscribe := n.sub.Unsubscribe
+	if n.durableName != "" {
+		unsubscribe = n.sub.Close
+	}
+	if err := unsubscribe(); err != nil {
 		return err
 	}
 	n.sub = nil
@@ -14,22 +14,7 @@ var once sync.Once
 
 func (n *NOOPRequesterFactory) GetRequester(num uint64) bench.Requester {
 	once.Do(func() {
-		instance = &noopRequester{}
+		instance = bench.RequesterFunc(func() error { return nil })
 	})
 	return instance
 }
-
-type noopRequester struct {
-}
-
-func (n *noopRequester) Setup() error {
-	return nil
-}
-
-func (n *noopRequester) Request() error {
-	return nil
-}
-
-func (n *noopRequester) Teardown() error {
-	return nil
-}
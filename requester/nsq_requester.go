@@ -12,44 +12,98 @@ import (
 
 // NSQRequesterFactory implements RequesterFactory by creating a Requester
 // which publishes messages to NSQ and waits to receive them.
+//
+// Messages are published to the nsqd at URL, or round-robin across
+// ProducerURLs if set. The consumer connects directly to every nsqd published
+// to unless LookupdURLs is set, in which case nsqd nodes are discovered
+// through nsqlookupd. In that case Setup publishes a message through each
+// producer so that the topic exists and is registered with nsqlookupd, then
+// waits for the consumer to connect to every nsqd and receive those
+// messages. Channel defaults to the topic name. MaxInFlight configures the
+// consumer. If Ephemeral is set, the topic and channel are ephemeral. If
+// Defer is set, messages are published with that delivery delay.
 type NSQRequesterFactory struct {
-	URL         string
-	PayloadSize int
-	Topic       string
+	URL          string
+	PayloadSize  int
+	Topic        string
+	Channel      string
+	ProducerURLs []string
+	LookupdURLs  []string
+	MaxInFlight  int
+	Ephemeral    bool
+	Defer        time.Duration
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (n *NSQRequesterFactory) GetRequester(num uint64) bench.Requester {
+	var (
+		topic   = n.Topic + "-" + strconv.FormatUint(num, 10)
+		channel = topic
+	)
+	if n.Channel != "" {
+		channel = n.Channel + "-" + strconv.FormatUint(num, 10)
+	}
+	if n.Ephemeral {
+		topic += "#ephemeral"
+		channel += "#ephemeral"
+	}
+	producerURLs := n.ProducerURLs
+	if len(producerURLs) == 0 {
+		producerURLs = []string{n.URL}
+	}
 	return &nsqRequester{
-		url:         n.URL,
-		payloadSize: n.PayloadSize,
-		topic:       n.Topic + "-" + strconv.FormatUint(num, 10),
-		channel:     n.Topic + "-" + strconv.FormatUint(num, 10),
+		url:          n.URL,
+		payloadSize:  n.PayloadSize,
+		topic:        topic,
+		channel:      channel,
+		producerURLs: producerURLs,
+		lookupdURLs:  n.LookupdURLs,
+		maxInFlight:  n.MaxInFlight,
+		deferred:     n.Defer,
 	}
 }
 
 // nsqRequester implements Requester by publishing a message to NSQ and
 // waiting to receive it.
 type nsqRequester struct {
-	url         string
-	payloadSize int
-	topic       string
-	channel     string
-	producer    *nsq.Producer
-	consumer    *nsq.Consumer
-	msg         []byte
-	msgChan     chan []byte
+	url          string
+	payloadSize  int
+	topic        string
+	channel      string
+	producerURLs []string
+	lookupdURLs  []string
+	maxInFlight  int
+	deferred     time.Duration
+	producers    []*nsq.Producer
+	next         int
+	consumer     *nsq.Consumer
+	msg          []byte
+	msgChan      chan []byte
 }
 
 // Setup prepares the Requester for benchmarking.
 func (n *nsqRequester) Setup() error {
 	config := nsq.NewConfig()
-	producer, err := nsq.NewProducer(n.url, config)
-	if err != nil {
-		return err
+	if n.maxInFlight > 0 {
+		config.MaxInFlight = n.maxInFlight
+	}
+	producers := make([]*nsq.Producer, 0, len(n.producerURLs))
+	stopProducers := func() {
+		for _, producer := range producers {
+			producer.Stop()
+		}
+	}
+	for _, url := range n.producerURLs {
+		producer, err := nsq.NewProducer(url, config)
+		if err != nil {
+			stopProducers()
+			return err
+		}
+		producers = append(producers, producer)
 	}
 	consumer, err := nsq.NewConsumer(n.topic, n.channel, config)
 	if err != nil {
+		stopProducers()
 		return err
 	}
 	n.msgChan = make(chan []byte)
@@ -58,11 +112,17 @@ func (n *nsqRequester) Setup() error {
 		n.msgChan <- m.Body
 		return nil
 	}), 1)
-	if err := consumer.ConnectToNSQD(n.url); err != nil {
-		producer.Stop()
+	if len(n.lookupdURLs) > 0 {
+		err = n.connectLookupds(consumer, producers)
+	} else {
+		err = consumer.ConnectToNSQDs(n.producerURLs)
+	}
+	if err != nil {
+		consumer.Stop()
+		stopProducers()
 		return err
 	}
-	n.producer = producer
+	n.producers = producers
 	n.consumer = consumer
 	n.msg = make([]byte, n.payloadSize)
 	for i := 0; i < n.payloadSize; i++ {
@@ -71,27 +131,66 @@ func (n *nsqRequester) Setup() error {
 	return nil
 }
 
+// connectLookupds creates the topic on every producer's nsqd, which registers
+// it with nsqlookupd, then connects the consumer through nsqlookupd and waits
+// until it has consumed the messages which created the topic.
+func (n *nsqRequester) connectLookupds(consumer *nsq.Consumer, producers []*nsq.Producer) error {
+	for _, producer := range producers {
+		if err := producer.Publish(n.topic, []byte("setup")); err != nil {
+			return err
+		}
+	}
+	if err := consumer.ConnectToNSQLookupds(n.lookupdURLs); err != nil {
+		return err
+	}
+	timeout := time.After(30 * time.Second)
+	for i := 0; i < len(producers); i++ {
+		select {
+		case <-n.msgChan:
+		case <-timeout:
+			return errors.New("requester: Setup timed out discovering nsqd nodes")
+		}
+	}
+	return nil
+}
+
 // Request performs a synchronous request to the system under test.
 func (n *nsqRequester) Request() error {
-	if err := n.producer.Publish(n.topic, n.msg); err != nil {
+	producer := n.producers[n.next]
+	n.next = (n.next + 1) % len(n.producers)
+	var err error
+	if n.deferred > 0 {
+		err = producer.DeferredPublish(n.topic, n.deferred, n.msg)
+	} else {
+		err = producer.Publish(n.topic, n.msg)
+	}
+	if err != nil {
 		return err
 	}
 	select {
 	case <-n.msgChan:
 		return nil
-	case <-time.After(30 * time.Second):
+	case <-time.After(n.deferred + 30*time.Second):
 		return errors.New("timeout")
 	}
 }
 
 // Teardown is called upon benchmark completion.
 func (n *nsqRequester) Teardown() error {
-	if err := n.consumer.DisconnectFromNSQD(n.url); err != nil {
-		return err
+	if len(n.lookupdURLs) > 0 {
+		n.consumer.Stop()
+	} else {
+		for _, url := range n.producerURLs {
+			if err := n.consumer.DisconnectFromNSQD(url); err != nil {
+				return err
+			}
+		}
+	}
+	for _, producer := range n.producers {
+		producer.Stop()
 	}
-	n.producer.Stop()
 
 	n.consumer = nil
-	n.producer = nil
+	n.producers = nil
 	return nil
 }
@@ -0,0 +1,35 @@
+package requester
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+)
+
+// correlationIDSize is the number of bytes prepended to a message to carry
+// the id which matches it to its request.
+const correlationIDSize = 16
+
+// newPayload returns a copy of payload if it is set, otherwise a random
+// payload of the given size.
+func newPayload(payload []byte, size int) []byte {
+	if payload != nil {
+		msg := make([]byte, len(payload))
+		copy(msg, payload)
+		return msg
+	}
+	msg := make([]byte, size)
+	for i := 0; i < size; i++ {
+		msg[i] = 'A' + uint8(rand.Intn(26))
+	}
+	return msg
+}
+
+// timeoutError replaces a network timeout with the standard Request timeout
+// error.
+func timeoutError(err error) error {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return errors.New("requester: Request timed out receiving")
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package requester
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/tylertreat/bench"
+)
+
+// PulsarRequesterFactory implements RequesterFactory by creating a Requester
+// which produces messages to Apache Pulsar and waits to consume them.
+//
+// SubscriptionType sets the consumer's subscription type and defaults to
+// pulsar.Exclusive. Batching is disabled unless Batching is set, in which
+// case BatchingMaxPublishDelay and BatchingMaxMessages control batching and
+// default to the client's defaults. Note that with batching enabled each
+// Request waits for its batch to be flushed.
+type PulsarRequesterFactory struct {
+	URL                     string
+	PayloadSize             int
+	Topic                   string
+	Subscription            string
+	SubscriptionType        pulsar.SubscriptionType
+	Batching                bool
+	BatchingMaxPublishDelay time.Duration
+	BatchingMaxMessages     uint
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (p *PulsarRequesterFactory) GetRequester(num uint64) bench.Requester {
+	subscription := p.Subscription
+	if subscription == "" {
+		subscription = "bench"
+	}
+	return &pulsarRequester{
+		url:                     p.URL,
+		payloadSize:             p.PayloadSize,
+		topic:                   p.Topic + "-" + strconv.FormatUint(num, 10),
+		subscription:            subscription + "-" + strconv.FormatUint(num, 10),
+		subscriptionType:        p.SubscriptionType,
+		batching:                p.Batching,
+		batchingMaxPublishDelay: p.BatchingMaxPublishDelay,
+		batchingMaxMessages:     p.BatchingMaxMessages,
+	}
+}
+
+// pulsarRequester implements Requester by producing a message to Apache
+// Pulsar and waiting to consume it.
+type pulsarRequester struct {
+	url                     string
+	payloadSize             int
+	topic                   string
+	subscription            string
+	subscriptionType        pulsar.SubscriptionType
+	batching                bool
+	batchingMaxPublishDelay time.Duration
+	batchingMaxMessages     uint
+	client                  pulsar.Client
+	producer                pulsar.Producer
+	consumer                pulsar.Consumer
+	msg                     *pulsar.ProducerMessage
+}
+
+// Setup prepares the Requester for benchmarking.
+func (p *pulsarRequester) Setup() error {
+	client, err := pulsar.NewClient(pulsar.ClientOptions{URL: p.url})
+	if err != nil {
+		return err
+	}
+	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
+		Topic:            p.topic,
+		SubscriptionName: p.subscription,
+		Type:             p.subscriptionType,
+	})
+	if err != nil {
+		client.Close()
+		return err
+	}
+	producer, err := client.CreateProducer(pulsar.ProducerOptions{
+		Topic:                   p.topic,
+		DisableBatching:         !p.batching,
+		BatchingMaxPublishDelay: p.batchingMaxPublishDelay,
+		BatchingMaxMessages:     p.batchingMaxMessages,
+	})
+	if err != nil {
+		consumer.Close()
+		client.Close()
+		return err
+	}
+	p.client = client
+	p.producer = producer
+	p.consumer = consumer
+	p.msg = &pulsar.ProducerMessage{Payload: newPayload(nil, p.payloadSize)}
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (p *pulsarRequester) Request() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := p.producer.Send(ctx, p.msg); err != nil {
+		return err
+	}
+	msg, err := p.consumer.Receive(ctx)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("requester: Request timed out receiving")
+		}
+		return err
+	}
+	p.consumer.Ack(msg)
+	return nil
+}
+
+// Teardown is called upon benchmark completion.
+func (p *pulsarRequester) Teardown() error {
+	p.producer.Close()
+	p.consumer.Close()
+	p.client.Close()
+	p.producer = nil
+	p.consumer = nil
+	p.client = nil
+	return nil
+}
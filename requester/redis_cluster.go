@@ -0,0 +1,279 @@
+package requester
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	// redisClusterSlots is the number of hash slots in a Redis Cluster.
+	redisClusterSlots = 16384
+
+	// maxRedisRedirects is the number of MOVED or ASK redirects followed
+	// before a command fails.
+	maxRedisRedirects = 5
+)
+
+// redisCommander is implemented by both redis.Conn and redisCluster.
+type redisCommander interface {
+	Do(command string, args ...interface{}) (interface{}, error)
+	Close() error
+}
+
+// dialRedis connects to the Redis server at url. If sentinels is non-empty,
+// the Sentinels are instead asked for the address of the named master.
+func dialRedis(url string, sentinels []string, master string) (redis.Conn, error) {
+	if len(sentinels) > 0 {
+		addr, err := sentinelMaster(sentinels, master)
+		if err != nil {
+			return nil, err
+		}
+		url = addr
+	}
+	return redis.Dial("tcp", url)
+}
+
+// sentinelMaster returns the address of the named master from the first
+// Sentinel which knows about it.
+func sentinelMaster(sentinels []string, master string) (string, error) {
+	err := errors.New("requester: no Redis Sentinels configured")
+	for _, sentinel := range sentinels {
+		var conn redis.Conn
+		conn, err = redis.Dial("tcp", sentinel)
+		if err != nil {
+			continue
+		}
+		var addr []string
+		addr, err = redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", master))
+		conn.Close()
+		if err != nil {
+			continue
+		}
+		if len(addr) != 2 {
+			err = errors.New("requester: unknown Redis master " + master)
+			continue
+		}
+		return net.JoinHostPort(addr[0], addr[1]), nil
+	}
+	return "", err
+}
+
+// redisCluster implements redisCommander by routing each command to the
+// Redis Cluster node which owns the hash slot of its first argument. The slot
+// map is loaded with CLUSTER SLOTS and kept up to date by following MOVED
+// redirects and by reloading it after a connection error. ASK redirects are
+// followed for the single command only.
+type redisCluster struct {
+	seeds []string
+	slots [redisClusterSlots]string
+	conns map[string]redis.Conn
+}
+
+// newRedisCluster connects to a Redis Cluster using the given seed nodes and
+// loads its slot map.
+func newRedisCluster(seeds ...string) (*redisCluster, error) {
+	c := &redisCluster{seeds: seeds, conns: make(map[string]redis.Conn)}
+	if err := c.refresh(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// refresh loads the slot map from the first seed node which responds.
+func (c *redisCluster) refresh() error {
+	err := errors.New("requester: no Redis Cluster nodes configured")
+	for _, seed := range c.seeds {
+		var conn redis.Conn
+		conn, err = c.conn(seed)
+		if err != nil {
+			continue
+		}
+		var ranges []interface{}
+		ranges, err = redis.Values(conn.Do("CLUSTER", "SLOTS"))
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				c.drop(seed)
+			}
+			continue
+		}
+		seedHost, _, _ := net.SplitHostPort(seed)
+		for _, r := range ranges {
+			// Each range is [start, end, [host, port, id], replicas...].
+			var (
+				fields     []interface{}
+				start, end int
+				master     []interface{}
+				host       string
+				port       int
+			)
+			if fields, err = redis.Values(r, nil); err != nil {
+				return err
+			}
+			if _, err = redis.Scan(fields, &start, &end, &master); err != nil {
+				return err
+			}
+			if _, err = redis.Scan(master, &host, &port); err != nil {
+				return err
+			}
+			if host == "" {
+				host = seedHost
+			}
+			addr := net.JoinHostPort(host, strconv.Itoa(port))
+			for slot := start; slot <= end && slot < redisClusterSlots; slot++ {
+				c.slots[slot] = addr
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+// conn returns the connection to the node at addr, dialing it if necessary.
+func (c *redisCluster) conn(addr string) (redis.Conn, error) {
+	if conn, ok := c.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := redis.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[addr] = conn
+	return conn, nil
+}
+
+// drop closes and forgets the connection to the node at addr, which can't be
+// used after a network error.
+func (c *redisCluster) drop(addr string) {
+	if conn, ok := c.conns[addr]; ok {
+		conn.Close()
+		delete(c.conns, addr)
+	}
+}
+
+// addr returns the address of the node which owns key, or of a seed node if
+// the owner is unknown.
+func (c *redisCluster) addr(key string) string {
+	if addr := c.slots[redisSlot(key)]; addr != "" {
+		return addr
+	}
+	return c.seeds[0]
+}
+
+// Do sends a command to the node owning the slot of its first argument and
+// returns the reply, following any redirects. Commands without arguments are
+// sent to a seed node.
+func (c *redisCluster) Do(command string, args ...interface{}) (interface{}, error) {
+	addr := c.seeds[0]
+	if len(args) > 0 {
+		addr = c.addr(redisKey(args[0]))
+	}
+	asking := false
+	for i := 0; i <= maxRedisRedirects; i++ {
+		conn, err := c.conn(addr)
+		if err != nil {
+			c.refresh()
+			return nil, err
+		}
+		if asking {
+			if _, err := conn.Do("ASKING"); err != nil {
+				c.nodeFailed(addr, err)
+				return nil, err
+			}
+		}
+		reply, err := conn.Do(command, args...)
+		if err == nil {
+			return reply, nil
+		}
+		redisErr, ok := err.(redis.Error)
+		if !ok {
+			c.nodeFailed(addr, err)
+			return reply, err
+		}
+		redirect := strings.Fields(string(redisErr))
+		if len(redirect) != 3 {
+			return reply, err
+		}
+		switch redirect[0] {
+		case "MOVED":
+			slot, err := strconv.Atoi(redirect[1])
+			if err != nil || slot < 0 || slot >= redisClusterSlots {
+				return reply, redisErr
+			}
+			c.slots[slot] = redirect[2]
+			asking = false
+		case "ASK":
+			asking = true
+		default:
+			return reply, err
+		}
+		addr = redirect[2]
+	}
+	return nil, errors.New("requester: too many Redis Cluster redirects")
+}
+
+// nodeFailed handles an error from the node at addr. Unless the node replied
+// with an error, the connection is broken, so it's dropped and the slot map is
+// reloaded in case the node failed over.
+func (c *redisCluster) nodeFailed(addr string, err error) {
+	if _, ok := err.(redis.Error); ok {
+		return
+	}
+	c.drop(addr)
+	c.refresh()
+}
+
+// Close closes the connections to every node.
+func (c *redisCluster) Close() error {
+	var firstErr error
+	for addr, conn := range c.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.conns, addr)
+	}
+	return firstErr
+}
+
+// redisKey returns the string form of a command argument.
+func redisKey(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case []byte:
+		return string(arg)
+	default:
+		return fmt.Sprint(arg)
+	}
+}
+
+// redisSlot returns the Redis Cluster hash slot of key, honoring hash tags.
+func redisSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16([]byte(key)) % redisClusterSlots)
+}
+
+// crc16 implements the CRC-16/XMODEM checksum used by Redis Cluster.
+func crc16(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
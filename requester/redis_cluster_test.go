@@ -0,0 +1,291 @@
+package requester
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestRedisSlot(t *testing.T) {
+	if crc := crc16([]byte("123456789")); crc != 0x31c3 {
+		t.Fatalf("expected crc16 0x31c3, got %#x", crc)
+	}
+	for key, slot := range map[string]int{
+		"foo":                 12182,
+		"bar":                 5061,
+		"{user1000}.follows":  3443,
+		"{user1000}.follower": 3443,
+		"user1000":            3443,
+		"foo{}{bar}":          8363,
+		"foo{{bar}}zap":       4015,
+		"{}foo":               9500,
+	} {
+		if got := redisSlot(key); got != slot {
+			t.Errorf("expected slot %d for %q, got %d", slot, key, got)
+		}
+	}
+}
+
+// fakeRedis is a minimal RESP server for testing, replying to each command
+// with the raw reply returned by handle.
+type fakeRedis struct {
+	listener net.Listener
+	handle   func(args []string) string
+
+	mu       sync.Mutex
+	conns    []net.Conn
+	commands []string
+}
+
+// newFakeRedis starts a fakeRedis listening on a local port.
+func newFakeRedis(t *testing.T, handle func(args []string) string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{listener: l, handle: handle}
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedis) port() string {
+	_, port, _ := net.SplitHostPort(f.addr())
+	return port
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, conn)
+		f.mu.Unlock()
+		go f.serveConn(conn)
+	}
+}
+
+func (f *fakeRedis) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		f.mu.Unlock()
+		if _, err := conn.Write([]byte(f.handle(args))); err != nil {
+			return
+		}
+	}
+}
+
+// received returns the commands received so far.
+func (f *fakeRedis) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+// dropConns closes every client connection, as a node restart would.
+func (f *fakeRedis) dropConns() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+}
+
+func (f *fakeRedis) close() {
+	f.listener.Close()
+	f.dropConns()
+}
+
+// readRESPCommand reads a command sent as an array of bulk strings.
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// bulk returns s as a RESP bulk string.
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// clusterSlots returns a CLUSTER SLOTS reply assigning every slot to the
+// node listening on port.
+func clusterSlots(port string) string {
+	return "*1\r\n*3\r\n:0\r\n:16383\r\n*2\r\n" + bulk("127.0.0.1") + ":" + port + "\r\n"
+}
+
+func TestRedisClusterRedirects(t *testing.T) {
+	var a, b *fakeRedis
+	a = newFakeRedis(t, func(args []string) string {
+		switch {
+		case args[0] == "CLUSTER":
+			return clusterSlots(a.port())
+		case args[0] == "ASKING":
+			return "+OK\r\n"
+		case args[1] == "moved":
+			return "-MOVED " + strconv.Itoa(redisSlot("moved")) + " " + b.addr() + "\r\n"
+		default:
+			return bulk("a")
+		}
+	})
+	defer a.close()
+	b = newFakeRedis(t, func(args []string) string {
+		if args[1] == "ask" {
+			return "-ASK " + strconv.Itoa(redisSlot("ask")) + " " + a.addr() + "\r\n"
+		}
+		return bulk("b")
+	})
+	defer b.close()
+
+	c, err := newRedisCluster(a.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// MOVED updates the slot map so later commands go straight to b.
+	for i := 0; i < 2; i++ {
+		reply, err := redis.String(c.Do("GET", "moved"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply != "b" {
+			t.Fatalf("expected reply from b, got %q", reply)
+		}
+	}
+	if got := len(a.received()); got != 2 {
+		t.Fatalf("expected a to receive CLUSTER SLOTS and one GET, got %v", a.received())
+	}
+	if addr := c.slots[redisSlot("moved")]; addr != b.addr() {
+		t.Fatalf("expected slot to move to %s, got %s", b.addr(), addr)
+	}
+
+	// ASK is followed, preceded by ASKING, without updating the slot map.
+	c.slots[redisSlot("ask")] = b.addr()
+	reply, err := redis.String(c.Do("GET", "ask"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "a" {
+		t.Fatalf("expected reply from a, got %q", reply)
+	}
+	received := a.received()
+	if n := len(received); n < 2 || received[n-2] != "ASKING" || received[n-1] != "GET ask" {
+		t.Fatalf("expected ASKING before GET, got %v", received)
+	}
+	if addr := c.slots[redisSlot("ask")]; addr != b.addr() {
+		t.Fatalf("expected ASK not to update the slot map, got %s", addr)
+	}
+}
+
+func TestRedisClusterReconnect(t *testing.T) {
+	var a *fakeRedis
+	a = newFakeRedis(t, func(args []string) string {
+		if args[0] == "CLUSTER" {
+			return clusterSlots(a.port())
+		}
+		return bulk("a")
+	})
+	defer a.close()
+
+	c, err := newRedisCluster(a.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Do("GET", "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The broken connection fails one command and is then replaced.
+	a.dropConns()
+	if _, err := c.Do("GET", "foo"); err == nil {
+		t.Fatal("expected error from dropped connection")
+	}
+	if _, err := c.Do("GET", "foo"); err != nil {
+		t.Fatalf("expected command to succeed after reconnecting, got %v", err)
+	}
+	var refreshes int
+	for _, cmd := range a.received() {
+		if cmd == "CLUSTER SLOTS" {
+			refreshes++
+		}
+	}
+	if refreshes != 2 {
+		t.Fatalf("expected slot map to be reloaded after the error, got %d loads", refreshes)
+	}
+}
+
+func TestSentinelMaster(t *testing.T) {
+	master := newFakeRedis(t, func(args []string) string {
+		return "+PONG\r\n"
+	})
+	defer master.close()
+	sentinel := newFakeRedis(t, func(args []string) string {
+		if len(args) == 3 && args[0] == "SENTINEL" && args[2] == "mymaster" {
+			return "*2\r\n" + bulk("127.0.0.1") + bulk(master.port())
+		}
+		return "*-1\r\n"
+	})
+	defer sentinel.close()
+
+	// The first Sentinel is unreachable, so the second is asked.
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	downAddr := down.Addr().String()
+	down.Close()
+
+	conn, err := dialRedis("", []string{downAddr, sentinel.addr()}, "mymaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		t.Fatal(err)
+	}
+	if got := master.received(); len(got) != 1 || got[0] != "PING" {
+		t.Fatalf("expected PING to reach the master, got %v", got)
+	}
+
+	if _, err := sentinelMaster([]string{sentinel.addr()}, "unknown"); err == nil {
+		t.Fatal("expected error for unknown master")
+	}
+}
@@ -1,6 +1,8 @@
 package requester
 
 import (
+	"fmt"
+	"math/rand"
 	"strconv"
 
 	"github.com/garyburd/redigo/redis"
@@ -9,34 +11,54 @@ import (
 
 // RedisRequesterFactory implements RequesterFactory by creating a Requester
 // which sends the configured command and arguments to Redis and waits for the
-// reply.
+// reply. If Cluster is set, URL is used as a seed node of a Redis Cluster and
+// each command is routed to the node owning the slot of its first argument.
+// If SentinelURLs is set, the Sentinels are asked for the address of the
+// master named MasterName instead of dialing URL.
 type RedisRequesterFactory struct {
-	URL     string
-	Command string
-	Args    []interface{}
+	URL          string
+	Command      string
+	Args         []interface{}
+	Cluster      bool
+	SentinelURLs []string
+	MasterName   string
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (r *RedisRequesterFactory) GetRequester(uint64) bench.Requester {
 	return &redisRequester{
-		url:     r.URL,
-		command: r.Command,
-		args:    r.Args,
+		url:        r.URL,
+		command:    r.Command,
+		args:       r.Args,
+		cluster:    r.Cluster,
+		sentinels:  r.SentinelURLs,
+		masterName: r.MasterName,
 	}
 }
 
 // redisRequester implements Requester by sending the configured command and
 // arguments to Redis and waiting for the reply.
 type redisRequester struct {
-	url     string
-	command string
-	args    []interface{}
-	conn    redis.Conn
+	url        string
+	command    string
+	args       []interface{}
+	cluster    bool
+	sentinels  []string
+	masterName string
+	conn       redisCommander
 }
 
 // Setup prepares the Requester for benchmarking.
 func (r *redisRequester) Setup() error {
-	conn, err := redis.Dial("tcp", r.url)
+	var (
+		conn redisCommander
+		err  error
+	)
+	if r.cluster {
+		conn, err = newRedisCluster(r.url)
+	} else {
+		conn, err = dialRedis(r.url, r.sentinels, r.masterName)
+	}
 	if err != nil {
 		return err
 	}
@@ -59,12 +81,116 @@ func (r *redisRequester) Teardown() error {
 	return nil
 }
 
+// RedisWorkloadRequesterFactory implements RequesterFactory by creating a
+// Requester which sends a pipeline of GET and SET commands to Redis and waits
+// for all of the replies. Each request pipelines Pipeline commands (one if
+// unset). Keys generates the key for each command, prefixed with KeyPrefix,
+// and defaults to sequential keys. ReadRatio is the fraction of commands
+// which are GETs, the remainder being SETs of a random ValueSize-byte value.
+type RedisWorkloadRequesterFactory struct {
+	URL       string
+	Pipeline  int
+	Keys      Generator
+	KeyPrefix string
+	ReadRatio float64
+	ValueSize int
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (r *RedisWorkloadRequesterFactory) GetRequester(uint64) bench.Requester {
+	pipeline := r.Pipeline
+	if pipeline < 1 {
+		pipeline = 1
+	}
+	keys := r.Keys
+	if keys == nil {
+		keys = Sequential(0)
+	}
+	return &redisWorkloadRequester{
+		url:       r.URL,
+		pipeline:  pipeline,
+		keys:      keys,
+		keyPrefix: r.KeyPrefix,
+		readRatio: r.ReadRatio,
+		valueSize: r.ValueSize,
+	}
+}
+
+// redisWorkloadRequester implements Requester by sending a pipeline of GET
+// and SET commands to Redis and waiting for all of the replies.
+type redisWorkloadRequester struct {
+	url       string
+	pipeline  int
+	keys      Generator
+	keyPrefix string
+	readRatio float64
+	valueSize int
+	conn      redis.Conn
+	value     []byte
+}
+
+// Setup prepares the Requester for benchmarking.
+func (r *redisWorkloadRequester) Setup() error {
+	conn, err := redis.Dial("tcp", r.url)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	r.value = newPayload(nil, r.valueSize)
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (r *redisWorkloadRequester) Request() error {
+	for i := 0; i < r.pipeline; i++ {
+		key := fmt.Sprint(r.keyPrefix, r.keys())
+		var err error
+		if rand.Float64() < r.readRatio {
+			err = r.conn.Send("GET", key)
+		} else {
+			err = r.conn.Send("SET", key, r.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := r.conn.Flush(); err != nil {
+		return err
+	}
+
+	// Read every reply so the connection stays in sync, but report the
+	// first error.
+	var firstErr error
+	for i := 0; i < r.pipeline; i++ {
+		if _, err := r.conn.Receive(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Teardown is called upon benchmark completion.
+func (r *redisWorkloadRequester) Teardown() error {
+	if err := r.conn.Close(); err != nil {
+		return err
+	}
+	r.conn = nil
+	return nil
+}
+
 // RedisPubSubRequesterFactory implements RequesterFactory by creating a
-// Requester which publishes messages to Redis and waits to receive them.
+// Requester which publishes messages to Redis and waits to receive them. If
+// Cluster is set, URL is used as a seed node of a Redis Cluster and the
+// Requester connects to the node owning the slot of its channel. If
+// SentinelURLs is set, the Sentinels are asked for the address of the master
+// named MasterName instead of dialing URL.
 type RedisPubSubRequesterFactory struct {
-	URL         string
-	PayloadSize int
-	Channel     string
+	URL          string
+	PayloadSize  int
+	Channel      string
+	Cluster      bool
+	SentinelURLs []string
+	MasterName   string
 }
 
 // redisPubSubRequester implements Requester by publishing a message to Redis
@@ -73,6 +199,9 @@ type redisPubSubRequester struct {
 	url           string
 	payloadSize   int
 	channel       string
+	cluster       bool
+	sentinels     []string
+	masterName    string
 	publishConn   redis.Conn
 	subscribeConn *redis.PubSubConn
 	msg           string
@@ -84,16 +213,23 @@ func (r *RedisPubSubRequesterFactory) GetRequester(num uint64) bench.Requester {
 		url:         r.URL,
 		payloadSize: r.PayloadSize,
 		channel:     r.Channel + "-" + strconv.FormatUint(num, 10),
+		cluster:     r.Cluster,
+		sentinels:   r.SentinelURLs,
+		masterName:  r.MasterName,
 	}
 }
 
 // Setup prepares the Requester for benchmarking.
 func (r *redisPubSubRequester) Setup() error {
-	pubConn, err := redis.Dial("tcp", r.url)
+	addr, err := r.addr()
 	if err != nil {
 		return err
 	}
-	subConn, err := redis.Dial("tcp", r.url)
+	pubConn, err := redis.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	subConn, err := redis.Dial("tcp", addr)
 	if err != nil {
 		return err
 	}
@@ -113,6 +249,23 @@ func (r *redisPubSubRequester) Setup() error {
 	return nil
 }
 
+// addr returns the address of the node to publish and subscribe on.
+func (r *redisPubSubRequester) addr() (string, error) {
+	switch {
+	case r.cluster:
+		cluster, err := newRedisCluster(r.url)
+		if err != nil {
+			return "", err
+		}
+		addr := cluster.addr(r.channel)
+		return addr, cluster.Close()
+	case len(r.sentinels) > 0:
+		return sentinelMaster(r.sentinels, r.masterName)
+	default:
+		return r.url, nil
+	}
+}
+
 // Request performs a synchronous request to the system under test.
 func (r *redisPubSubRequester) Request() error {
 	if err := r.publishConn.Send("PUBLISH", r.channel, r.msg); err != nil {
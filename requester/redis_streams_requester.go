@@ -0,0 +1,186 @@
+package requester
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/tylertreat/bench"
+)
+
+// redisStreamsReadCount is the maximum number of entries read at once by the
+// Redis Streams Requester.
+const redisStreamsReadCount = 16
+
+// RedisStreamsRequesterFactory implements RequesterFactory by creating a
+// Requester which adds entries to a Redis Stream and waits to read them back
+// through a consumer group. Group defaults to "bench" and, if it already
+// exists, is moved to the end of the stream in Setup. If MaxLen is set, the
+// stream is trimmed to approximately that many entries on each add. If Ack is
+// set, each entry is acknowledged with XACK once read, including entries from
+// earlier requests which timed out and are skipped, otherwise entries are left
+// in the group's pending entries list.
+type RedisStreamsRequesterFactory struct {
+	URL         string
+	PayloadSize int
+	Stream      string
+	Group       string
+	MaxLen      int64
+	Ack         bool
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (r *RedisStreamsRequesterFactory) GetRequester(num uint64) bench.Requester {
+	group := r.Group
+	if group == "" {
+		group = "bench"
+	}
+	return &redisStreamsRequester{
+		url:         r.URL,
+		payloadSize: r.PayloadSize,
+		stream:      r.Stream + "-" + strconv.FormatUint(num, 10),
+		group:       group,
+		consumer:    "consumer-" + strconv.FormatUint(num, 10),
+		maxLen:      r.MaxLen,
+		ack:         r.Ack,
+	}
+}
+
+// redisStreamsRequester implements Requester by adding an entry to a Redis
+// Stream and waiting to read it back through a consumer group.
+type redisStreamsRequester struct {
+	url         string
+	payloadSize int
+	stream      string
+	group       string
+	consumer    string
+	maxLen      int64
+	ack         bool
+	produceConn redis.Conn
+	consumeConn redis.Conn
+	addArgs     redis.Args
+}
+
+// Setup prepares the Requester for benchmarking.
+func (r *redisStreamsRequester) Setup() error {
+	produceConn, err := redis.Dial("tcp", r.url)
+	if err != nil {
+		return err
+	}
+	consumeConn, err := redis.Dial("tcp", r.url)
+	if err != nil {
+		produceConn.Close()
+		return err
+	}
+	_, err = consumeConn.Do("XGROUP", "CREATE", r.stream, r.group, "$", "MKSTREAM")
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		// Skip entries left over from a previous run.
+		_, err = consumeConn.Do("XGROUP", "SETID", r.stream, r.group, "$")
+	}
+	if err != nil {
+		produceConn.Close()
+		consumeConn.Close()
+		return err
+	}
+	r.produceConn = produceConn
+	r.consumeConn = consumeConn
+
+	r.addArgs = redis.Args{r.stream}
+	if r.maxLen > 0 {
+		r.addArgs = r.addArgs.Add("MAXLEN", "~", r.maxLen)
+	}
+	r.addArgs = r.addArgs.Add("*", "data", newPayload(nil, r.payloadSize))
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (r *redisStreamsRequester) Request() error {
+	id, err := redis.String(r.produceConn.Do("XADD", r.addArgs...))
+	if err != nil {
+		return err
+	}
+
+	// Entries added by earlier requests which timed out may still be queued
+	// ahead of this one, so read until it arrives, skipping the stale ones.
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		block := time.Until(deadline) / time.Millisecond
+		if block <= 0 {
+			return errors.New("requester: Request timed out receiving")
+		}
+		reply, err := r.consumeConn.Do("XREADGROUP",
+			"GROUP", r.group, r.consumer,
+			"COUNT", redisStreamsReadCount,
+			"BLOCK", int64(block),
+			"STREAMS", r.stream, ">",
+		)
+		if err != nil {
+			return err
+		}
+		if reply == nil {
+			return errors.New("requester: Request timed out receiving")
+		}
+		ids, err := streamEntryIDs(reply)
+		if err != nil {
+			return err
+		}
+		if r.ack {
+			args := redis.Args{r.stream, r.group}.AddFlat(ids)
+			if _, err := r.consumeConn.Do("XACK", args...); err != nil {
+				return err
+			}
+		}
+		found := false
+		for _, readID := range ids {
+			if readID == id {
+				found = true
+			}
+		}
+		if found {
+			return nil
+		}
+	}
+}
+
+// streamEntryIDs returns the ids of the entries in an XREADGROUP reply for a
+// single stream, which has the form [[stream, [[id, [field, value]]...]]].
+func streamEntryIDs(reply interface{}) ([]string, error) {
+	streams, err := redis.Values(reply, nil)
+	if err != nil || len(streams) != 1 {
+		return nil, errors.New("requester: unexpected XREADGROUP reply")
+	}
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil || len(stream) != 2 {
+		return nil, errors.New("requester: unexpected XREADGROUP reply")
+	}
+	entries, err := redis.Values(stream[1], nil)
+	if err != nil {
+		return nil, errors.New("requester: unexpected XREADGROUP reply")
+	}
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		entry, err := redis.Values(e, nil)
+		if err != nil || len(entry) != 2 {
+			return nil, errors.New("requester: unexpected XREADGROUP reply")
+		}
+		if ids[i], err = redis.String(entry[0], nil); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// Teardown is called upon benchmark completion.
+func (r *redisStreamsRequester) Teardown() error {
+	if err := r.produceConn.Close(); err != nil {
+		return err
+	}
+	r.produceConn = nil
+	if err := r.consumeConn.Close(); err != nil {
+		return err
+	}
+	r.consumeConn = nil
+	return nil
+}
@@ -0,0 +1,195 @@
+package requester
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/tylertreat/bench"
+)
+
+// SQLRequesterFactory implements RequesterFactory by creating a Requester
+// which runs a statement against a database/sql driver. The driver must be
+// registered by importing it in the benchmark program. Args generates the
+// statement arguments for each request. If Query is set, the statement is
+// run as a query and all returned rows are drained, otherwise it is executed.
+// If Prepare is set, the statement is prepared once in Setup and reused. If
+// Transaction is set, each request runs inside its own transaction which is
+// committed before returning.
+//
+// Every connection shares a single connection pool, opened by the first
+// connection to be set up and closed once the last is torn down, so
+// MaxOpenConns, MaxIdleConns and ConnMaxLifetime bound the database
+// connections used across the whole benchmark.
+type SQLRequesterFactory struct {
+	Driver          string
+	DSN             string
+	Statement       string
+	Args            []Generator
+	Query           bool
+	Prepare         bool
+	Transaction     bool
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	mu   sync.Mutex
+	db   *sql.DB
+	refs int
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (s *SQLRequesterFactory) GetRequester(uint64) bench.Requester {
+	return &sqlRequester{
+		factory:     s,
+		statement:   s.Statement,
+		args:        s.Args,
+		query:       s.Query,
+		prepare:     s.Prepare,
+		transaction: s.Transaction,
+	}
+}
+
+// acquire returns the shared connection pool, opening it if necessary.
+func (s *SQLRequesterFactory) acquire() (*sql.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		db, err := sql.Open(s.Driver, s.DSN)
+		if err != nil {
+			return nil, err
+		}
+		db.SetMaxOpenConns(s.MaxOpenConns)
+		if s.MaxIdleConns != 0 {
+			db.SetMaxIdleConns(s.MaxIdleConns)
+		}
+		db.SetConnMaxLifetime(s.ConnMaxLifetime)
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		s.db = db
+	}
+	s.refs++
+	return s.db, nil
+}
+
+// release closes the shared connection pool once no connection is using it.
+func (s *SQLRequesterFactory) release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs--
+	if s.refs > 0 {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// sqlRequester implements Requester by running a statement against a
+// database/sql driver.
+type sqlRequester struct {
+	factory     *SQLRequesterFactory
+	statement   string
+	args        []Generator
+	query       bool
+	prepare     bool
+	transaction bool
+	db          *sql.DB
+	stmt        *sql.Stmt
+}
+
+// Setup prepares the Requester for benchmarking.
+func (s *sqlRequester) Setup() error {
+	db, err := s.factory.acquire()
+	if err != nil {
+		return err
+	}
+	if s.prepare {
+		stmt, err := db.Prepare(s.statement)
+		if err != nil {
+			s.factory.release()
+			return err
+		}
+		s.stmt = stmt
+	}
+	s.db = db
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (s *sqlRequester) Request() error {
+	args := generate(s.args)
+	if !s.transaction {
+		return s.run(s.db, s.stmt, args)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt := s.stmt
+	if stmt != nil {
+		stmt = tx.Stmt(stmt)
+	}
+	if err := s.run(tx, stmt, args); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlRunner is implemented by both *sql.DB and *sql.Tx.
+type sqlRunner interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// run executes the statement, or the prepared stmt if it's non-nil, using
+// the given runner.
+func (s *sqlRequester) run(runner sqlRunner, stmt *sql.Stmt, args []interface{}) error {
+	if !s.query {
+		var err error
+		if stmt != nil {
+			_, err = stmt.Exec(args...)
+		} else {
+			_, err = runner.Exec(s.statement, args...)
+		}
+		return err
+	}
+
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if stmt != nil {
+		rows, err = stmt.Query(args...)
+	} else {
+		rows, err = runner.Query(s.statement, args...)
+	}
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	return rows.Close()
+}
+
+// Teardown is called upon benchmark completion.
+func (s *sqlRequester) Teardown() error {
+	var err error
+	if s.stmt != nil {
+		err = s.stmt.Close()
+		s.stmt = nil
+	}
+	s.db = nil
+	if rerr := s.factory.release(); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package requester
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newSQLiteDSN creates an SQLite database with a kv table and returns its
+// DSN along with a function removing it.
+func newSQLiteDSN(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "bench-sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn := filepath.Join(dir, "bench.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE kv (k INTEGER PRIMARY KEY, v TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	return dsn, func() { os.RemoveAll(dir) }
+}
+
+// runSQLRequesters sets up the given number of Requesters from factory,
+// issues requests on each and tears them down.
+func runSQLRequesters(t *testing.T, factory *SQLRequesterFactory, connections, requests int) {
+	requesters := make([]*sqlRequester, connections)
+	for i := range requesters {
+		requesters[i] = factory.GetRequester(uint64(i)).(*sqlRequester)
+		if err := requesters[i].Setup(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, r := range requesters {
+		if r.db != requesters[0].db {
+			t.Fatal("expected connections to share a connection pool")
+		}
+	}
+	if max := requesters[0].db.Stats().MaxOpenConnections; max != factory.MaxOpenConns {
+		t.Fatalf("expected MaxOpenConnections %d, got %d", factory.MaxOpenConns, max)
+	}
+	for i := 0; i < requests; i++ {
+		for _, r := range requesters {
+			if err := r.Request(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, r := range requesters {
+		if err := r.Teardown(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if factory.db != nil {
+		t.Fatal("expected connection pool to be closed after last Teardown")
+	}
+}
+
+// countRows returns the number of rows in the kv table.
+func countRows(t *testing.T, dsn string) int {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM kv").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSQLRequester(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		prepare     bool
+		transaction bool
+	}{
+		{name: "direct"},
+		{name: "prepare", prepare: true},
+		{name: "transaction", transaction: true},
+		{name: "prepare+transaction", prepare: true, transaction: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dsn, cleanup := newSQLiteDSN(t)
+			defer cleanup()
+
+			insert := &SQLRequesterFactory{
+				Driver:       "sqlite3",
+				DSN:          dsn,
+				Statement:    "INSERT INTO kv (k, v) VALUES (?, ?)",
+				Args:         []Generator{Sequential(0), RandomString(16)},
+				Prepare:      tc.prepare,
+				Transaction:  tc.transaction,
+				MaxOpenConns: 1,
+			}
+			runSQLRequesters(t, insert, 3, 10)
+			if n := countRows(t, dsn); n != 30 {
+				t.Fatalf("expected 30 rows, got %d", n)
+			}
+
+			query := &SQLRequesterFactory{
+				Driver:       "sqlite3",
+				DSN:          dsn,
+				Statement:    "SELECT k, v FROM kv WHERE k >= ?",
+				Args:         []Generator{RandomInt(30)},
+				Query:        true,
+				Prepare:      tc.prepare,
+				Transaction:  tc.transaction,
+				MaxOpenConns: 1,
+			}
+			runSQLRequesters(t, query, 3, 10)
+		})
+	}
+}
+
+func TestSQLRequesterError(t *testing.T) {
+	dsn, cleanup := newSQLiteDSN(t)
+	defer cleanup()
+
+	factory := &SQLRequesterFactory{
+		Driver:      "sqlite3",
+		DSN:         dsn,
+		Statement:   "INSERT INTO kv (k, v) VALUES (?, ?)",
+		Args:        []Generator{Constant(1), Constant("v")},
+		Transaction: true,
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Request(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Request(); err == nil {
+		t.Fatal("expected duplicate key error")
+	}
+	if err := r.Teardown(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, dsn); n != 1 {
+		t.Fatalf("expected failed transaction to be rolled back, got %d rows", n)
+	}
+}
@@ -0,0 +1,121 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"time"
+
+	"github.com/tylertreat/bench"
+)
+
+// TCPRequesterFactory implements RequesterFactory by creating a Requester
+// which writes a payload to a stream socket and reads back the response.
+// Network is "tcp" (the default), "tcp4", "tcp6" or "unix". If Payload is set
+// it is written as-is, otherwise a random payload of PayloadSize bytes is
+// generated. If Delimiter is set, the response is read until the delimiter is
+// seen, otherwise ResponseSize bytes are read. If neither is set, the
+// response is expected to be the same length as the payload, as with an echo
+// server.
+type TCPRequesterFactory struct {
+	Network      string
+	Addr         string
+	PayloadSize  int
+	Payload      []byte
+	ResponseSize int
+	Delimiter    []byte
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (t *TCPRequesterFactory) GetRequester(uint64) bench.Requester {
+	network := t.Network
+	if network == "" {
+		network = "tcp"
+	}
+	return &tcpRequester{
+		network:      network,
+		addr:         t.Addr,
+		payloadSize:  t.PayloadSize,
+		payload:      t.Payload,
+		responseSize: t.ResponseSize,
+		delimiter:    t.Delimiter,
+	}
+}
+
+// tcpRequester implements Requester by writing a payload to a stream socket
+// and reading back the response.
+type tcpRequester struct {
+	network      string
+	addr         string
+	payloadSize  int
+	payload      []byte
+	responseSize int
+	delimiter    []byte
+	conn         net.Conn
+	reader       *bufio.Reader
+	msg          []byte
+	buf          []byte
+}
+
+// Setup prepares the Requester for benchmarking.
+func (t *tcpRequester) Setup() error {
+	conn, err := net.Dial(t.network, t.addr)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	t.reader = bufio.NewReader(conn)
+	t.msg = newPayload(t.payload, t.payloadSize)
+	if t.responseSize == 0 {
+		t.responseSize = len(t.msg)
+	}
+	t.buf = make([]byte, t.responseSize)
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (t *tcpRequester) Request() error {
+	if err := t.conn.SetDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		return err
+	}
+	if _, err := t.conn.Write(t.msg); err != nil {
+		return err
+	}
+	if len(t.delimiter) > 0 {
+		return timeoutError(readDelimited(t.reader, t.delimiter))
+	}
+	_, err := io.ReadFull(t.reader, t.buf)
+	return timeoutError(err)
+}
+
+// Teardown is called upon benchmark completion.
+func (t *tcpRequester) Teardown() error {
+	if err := t.conn.Close(); err != nil {
+		return err
+	}
+	t.conn = nil
+	t.reader = nil
+	return nil
+}
+
+// readDelimited reads from r until the delimiter has been consumed.
+func readDelimited(r *bufio.Reader, delimiter []byte) error {
+	var (
+		last = delimiter[len(delimiter)-1]
+		resp []byte
+	)
+	for {
+		chunk, err := r.ReadSlice(last)
+		resp = append(resp, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if bytes.HasSuffix(resp, delimiter) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package requester
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tylertreat/bench"
+)
+
+// maxDatagramSize is the largest datagram the UDP Requester will receive.
+const maxDatagramSize = 65535
+
+// UDPRequesterFactory implements RequesterFactory by creating a Requester
+// which writes a payload to a datagram socket and reads back the response.
+// Network is "udp" (the default), "udp4", "udp6" or "unixgram". If Payload is
+// set it is written as-is, otherwise a random payload of PayloadSize bytes is
+// generated. Datagrams are read until the response is complete: if Delimiter
+// is set, until the delimiter is seen, otherwise until ResponseSize bytes have
+// been received. If neither is set, a single datagram is taken as the
+// response. Since datagrams carry no request id, replies which arrive after
+// their Request timed out are discarded before the next Request is sent, but
+// a reply arriving later still is taken as the next Request's response.
+type UDPRequesterFactory struct {
+	Network      string
+	Addr         string
+	PayloadSize  int
+	Payload      []byte
+	ResponseSize int
+	Delimiter    []byte
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (u *UDPRequesterFactory) GetRequester(uint64) bench.Requester {
+	network := u.Network
+	if network == "" {
+		network = "udp"
+	}
+	return &udpRequester{
+		network:      network,
+		addr:         u.Addr,
+		payloadSize:  u.PayloadSize,
+		payload:      u.Payload,
+		responseSize: u.ResponseSize,
+		delimiter:    u.Delimiter,
+	}
+}
+
+// udpRequester implements Requester by writing a payload to a datagram socket
+// and reading back the response.
+type udpRequester struct {
+	network      string
+	addr         string
+	payloadSize  int
+	payload      []byte
+	responseSize int
+	delimiter    []byte
+	conn         net.Conn
+	localDir     string
+	msg          []byte
+	buf          []byte
+	resp         []byte
+	stale        bool
+}
+
+// Setup prepares the Requester for benchmarking.
+func (u *udpRequester) Setup() error {
+	conn, err := u.dial()
+	if err != nil {
+		return err
+	}
+	u.conn = conn
+	u.msg = newPayload(u.payload, u.payloadSize)
+	u.buf = make([]byte, maxDatagramSize)
+	return nil
+}
+
+// dial connects to the configured address. Unix datagram sockets must be
+// bound to a local path in order to receive replies, so one is created in a
+// temporary directory.
+func (u *udpRequester) dial() (net.Conn, error) {
+	if u.network != "unixgram" {
+		return net.Dial(u.network, u.addr)
+	}
+	dir, err := ioutil.TempDir("", "bench")
+	if err != nil {
+		return nil, err
+	}
+	laddr := &net.UnixAddr{Name: filepath.Join(dir, "requester.sock"), Net: u.network}
+	raddr := &net.UnixAddr{Name: u.addr, Net: u.network}
+	conn, err := net.DialUnix(u.network, laddr, raddr)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	u.localDir = dir
+	return conn, nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (u *udpRequester) Request() error {
+	if u.stale {
+		u.drain()
+		u.stale = false
+	}
+	if err := u.conn.SetDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		return err
+	}
+	if _, err := u.conn.Write(u.msg); err != nil {
+		return err
+	}
+	u.resp = u.resp[:0]
+	for {
+		n, err := u.conn.Read(u.buf)
+		if err != nil {
+			// The reply, or the rest of it, may still arrive.
+			u.stale = true
+			return timeoutError(err)
+		}
+		u.resp = append(u.resp, u.buf[:n]...)
+		switch {
+		case len(u.delimiter) > 0:
+			if bytes.HasSuffix(u.resp, u.delimiter) {
+				return nil
+			}
+		case len(u.resp) >= u.responseSize:
+			return nil
+		}
+	}
+}
+
+// drain discards datagrams already received, which belong to an earlier
+// Request.
+func (u *udpRequester) drain() {
+	// A deadline in the past fails reads without receiving queued
+	// datagrams, so allow a moment for them to be read.
+	u.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	for {
+		if _, err := u.conn.Read(u.buf); err != nil {
+			return
+		}
+	}
+}
+
+// Teardown is called upon benchmark completion.
+func (u *udpRequester) Teardown() error {
+	if err := u.conn.Close(); err != nil {
+		return err
+	}
+	u.conn = nil
+	if u.localDir != "" {
+		if err := os.RemoveAll(u.localDir); err != nil {
+			return err
+		}
+		u.localDir = ""
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package requester
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tylertreat/bench"
+)
+
+// WebSocketRequesterFactory implements RequesterFactory by creating a
+// Requester which sends frames over a WebSocket and waits for the reply. If
+// Payload is set it is sent as-is, otherwise a random payload of PayloadSize
+// bytes is generated. If Correlate is set, each frame is prefixed with a
+// request id and the Requester waits for the reply carrying the same prefix,
+// otherwise the next message received is taken as the reply.
+type WebSocketRequesterFactory struct {
+	URL         string
+	Header      http.Header
+	PayloadSize int
+	Payload     []byte
+	Binary      bool
+	Correlate   bool
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (w *WebSocketRequesterFactory) GetRequester(uint64) bench.Requester {
+	messageType := websocket.TextMessage
+	if w.Binary {
+		messageType = websocket.BinaryMessage
+	}
+	return &webSocketRequester{
+		url:         w.URL,
+		header:      w.Header,
+		payloadSize: w.PayloadSize,
+		payload:     w.Payload,
+		messageType: messageType,
+		correlate:   w.Correlate,
+	}
+}
+
+// webSocketRequester implements Requester by sending a frame over a WebSocket
+// and waiting for the reply.
+type webSocketRequester struct {
+	url         string
+	header      http.Header
+	payloadSize int
+	payload     []byte
+	messageType int
+	correlate   bool
+	conn        *websocket.Conn
+	msg         []byte
+	seq         uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (w *webSocketRequester) Setup() error {
+	conn, _, err := websocket.DefaultDialer.Dial(w.url, w.header)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	msg := newPayload(w.payload, w.payloadSize)
+	if w.correlate {
+		// Reserve room for the id ahead of the payload.
+		msg = append(make([]byte, correlationIDSize), msg...)
+	}
+	w.msg = msg
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (w *webSocketRequester) Request() error {
+	var id []byte
+	if w.correlate {
+		w.seq++
+		id = []byte(fmt.Sprintf("%016x", w.seq))
+		copy(w.msg, id)
+	}
+	if err := w.conn.WriteMessage(w.messageType, w.msg); err != nil {
+		return err
+	}
+	if err := w.conn.SetReadDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		return err
+	}
+	for {
+		_, reply, err := w.conn.ReadMessage()
+		if err != nil {
+			return timeoutError(err)
+		}
+		if !w.correlate || bytes.HasPrefix(reply, id) {
+			return nil
+		}
+	}
+}
+
+// Teardown is called upon benchmark completion.
+func (w *webSocketRequester) Teardown() error {
+	w.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second),
+	)
+	if err := w.conn.Close(); err != nil {
+		return err
+	}
+	w.conn = nil
+	return nil
+}
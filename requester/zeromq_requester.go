@@ -0,0 +1,305 @@
+package requester
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	zmq "github.com/pebbe/zmq4"
+	"github.com/tylertreat/bench"
+)
+
+// ZeroMQPattern is a ZeroMQ messaging pattern used by the ZeroMQ Requester.
+type ZeroMQPattern string
+
+// ZeroMQ patterns.
+const (
+	// ZeroMQReqRep sends a request on a REQ socket and waits for the reply.
+	ZeroMQReqRep ZeroMQPattern = "reqrep"
+
+	// ZeroMQPubSub publishes a message on a PUB socket and waits to receive
+	// it on a SUB socket.
+	ZeroMQPubSub ZeroMQPattern = "pubsub"
+
+	// ZeroMQPushPull pushes a message on a PUSH socket and waits to pull it
+	// from a PULL socket.
+	ZeroMQPushPull ZeroMQPattern = "pushpull"
+)
+
+// ZeroMQRequesterFactory implements RequesterFactory by creating a Requester
+// which sends messages over ZeroMQ sockets using the given Pattern.
+//
+// For PUB/SUB and PUSH/PULL, and for REQ/REP when Responder is set, each
+// connection binds its own receiving socket in-process and connects its
+// sending socket to it. URL is the endpoint to bind: inproc and ipc
+// endpoints have the connection number appended, while tcp endpoints should
+// use a wildcard port, e.g. "tcp://127.0.0.1:*". URL defaults to
+// "inproc://bench". When Responder is not set, REQ/REP connects to an
+// external echo server listening on URL.
+type ZeroMQRequesterFactory struct {
+	URL         string
+	Pattern     ZeroMQPattern
+	PayloadSize int
+	Responder   bool
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (z *ZeroMQRequesterFactory) GetRequester(num uint64) bench.Requester {
+	url := z.URL
+	if url == "" {
+		url = "inproc://bench"
+	}
+	endpoint := url
+	if !strings.HasPrefix(url, "tcp://") {
+		endpoint = url + "-" + strconv.FormatUint(num, 10)
+	}
+	return &zeromqRequester{
+		url:         url,
+		endpoint:    endpoint,
+		pattern:     z.Pattern,
+		payloadSize: z.PayloadSize,
+		responder:   z.Responder,
+	}
+}
+
+// zeromqRequester implements Requester by sending a message over a ZeroMQ
+// socket and waiting to receive it, or its reply, on another.
+type zeromqRequester struct {
+	url         string
+	endpoint    string
+	pattern     ZeroMQPattern
+	payloadSize int
+	responder   bool
+	send        *zmq.Socket
+	recv        *zmq.Socket
+	msg         []byte
+	done        chan struct{}
+	stopped     chan struct{}
+}
+
+// Setup prepares the Requester for benchmarking.
+func (z *zeromqRequester) Setup() error {
+	z.msg = newPayload(nil, z.payloadSize)
+	switch z.pattern {
+	case ZeroMQReqRep:
+		return z.setupReqRep()
+	case ZeroMQPubSub:
+		return z.setupPipe(zmq.PUB, zmq.SUB)
+	case ZeroMQPushPull:
+		return z.setupPipe(zmq.PUSH, zmq.PULL)
+	default:
+		return fmt.Errorf("requester: unknown ZeroMQ pattern %q", z.pattern)
+	}
+}
+
+// setupReqRep connects a REQ socket to the responder, starting an echo
+// responder in-process if configured.
+func (z *zeromqRequester) setupReqRep() error {
+	url := z.url
+	if z.responder {
+		rep, endpoint, err := bindZeroMQ(zmq.REP, z.endpoint)
+		if err != nil {
+			return err
+		}
+		// Poll so that the responder notices when it's stopped.
+		if err := rep.SetRcvtimeo(100 * time.Millisecond); err != nil {
+			rep.Close()
+			return err
+		}
+		z.done = make(chan struct{})
+		z.stopped = make(chan struct{})
+		go z.respond(rep)
+		url = endpoint
+	}
+
+	req, err := zmq.NewSocket(zmq.REQ)
+	if err != nil {
+		z.stopResponder()
+		return err
+	}
+	// Allow a new request after a timed out one and discard its late reply.
+	if err := req.SetReqRelaxed(1); err != nil {
+		req.Close()
+		z.stopResponder()
+		return err
+	}
+	if err := req.SetReqCorrelate(1); err != nil {
+		req.Close()
+		z.stopResponder()
+		return err
+	}
+	if err := req.SetRcvtimeo(30 * time.Second); err != nil {
+		req.Close()
+		z.stopResponder()
+		return err
+	}
+	if err := req.Connect(url); err != nil {
+		req.Close()
+		z.stopResponder()
+		return err
+	}
+	z.send = req
+	z.recv = req
+	return nil
+}
+
+// setupPipe binds the receiving socket and connects the sending socket to
+// it, waiting until messages flow between them.
+func (z *zeromqRequester) setupPipe(sendType, recvType zmq.Type) error {
+	recv, endpoint, err := bindZeroMQ(recvType, z.endpoint)
+	if err != nil {
+		return err
+	}
+	if recvType == zmq.SUB {
+		if err := recv.SetSubscribe(""); err != nil {
+			recv.Close()
+			return err
+		}
+	}
+	send, err := zmq.NewSocket(sendType)
+	if err != nil {
+		recv.Close()
+		return err
+	}
+	if err := send.Connect(endpoint); err != nil {
+		send.Close()
+		recv.Close()
+		return err
+	}
+	z.send = send
+	z.recv = recv
+	if err := z.awaitPipe(); err != nil {
+		z.Teardown()
+		return err
+	}
+	return nil
+}
+
+// awaitPipe sends messages until one is received, since PUB/SUB drops
+// messages until the subscription has propagated, then discards any
+// messages still in flight.
+func (z *zeromqRequester) awaitPipe() error {
+	if err := z.recv.SetRcvtimeo(100 * time.Millisecond); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			return errors.New("requester: ZeroMQ sockets failed to connect")
+		}
+		if _, err := z.send.SendBytes(z.msg, 0); err != nil {
+			return err
+		}
+		_, err := z.recv.RecvBytes(0)
+		if err == nil {
+			break
+		}
+		if !isZeroMQTimeout(err) {
+			return err
+		}
+	}
+	for {
+		_, err := z.recv.RecvBytes(0)
+		if isZeroMQTimeout(err) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return z.recv.SetRcvtimeo(30 * time.Second)
+}
+
+// respond echoes requests received on rep until the Requester is torn down.
+func (z *zeromqRequester) respond(rep *zmq.Socket) {
+	defer close(z.stopped)
+	defer rep.Close()
+	for {
+		select {
+		case <-z.done:
+			return
+		default:
+		}
+		msg, err := rep.RecvBytes(0)
+		if err != nil {
+			if isZeroMQTimeout(err) {
+				continue
+			}
+			return
+		}
+		if _, err := rep.SendBytes(msg, 0); err != nil {
+			return
+		}
+	}
+}
+
+// stopResponder stops the in-process responder, if any, and waits for it to
+// close its socket.
+func (z *zeromqRequester) stopResponder() {
+	if z.done == nil {
+		return
+	}
+	close(z.done)
+	<-z.stopped
+	z.done = nil
+	z.stopped = nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (z *zeromqRequester) Request() error {
+	if _, err := z.send.SendBytes(z.msg, 0); err != nil {
+		return err
+	}
+	if _, err := z.recv.RecvBytes(0); err != nil {
+		if isZeroMQTimeout(err) {
+			return errors.New("requester: Request timed out receiving")
+		}
+		return err
+	}
+	return nil
+}
+
+// Teardown is called upon benchmark completion.
+func (z *zeromqRequester) Teardown() error {
+	var err error
+	if z.send != nil {
+		err = z.send.Close()
+	}
+	if z.recv != nil && z.recv != z.send {
+		if rerr := z.recv.Close(); err == nil {
+			err = rerr
+		}
+	}
+	z.send = nil
+	z.recv = nil
+	z.stopResponder()
+	return err
+}
+
+// bindZeroMQ creates a socket of the given type bound to endpoint and returns
+// the endpoint it's actually bound to, which differs when a wildcard port is
+// used.
+func bindZeroMQ(t zmq.Type, endpoint string) (*zmq.Socket, string, error) {
+	sock, err := zmq.NewSocket(t)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := sock.Bind(endpoint); err != nil {
+		sock.Close()
+		return nil, "", err
+	}
+	bound, err := sock.GetLastEndpoint()
+	if err != nil {
+		sock.Close()
+		return nil, "", err
+	}
+	return sock, bound, nil
+}
+
+// isZeroMQTimeout reports whether err is the result of a receive timeout.
+func isZeroMQTimeout(err error) bool {
+	return err != nil && zmq.AsErrno(err) == zmq.Errno(syscall.EAGAIN)
+}